@@ -0,0 +1,24 @@
+// Serves a mock OpenID Connect provider, which logs every user in without credentials. Start the
+// API with -oidc-issuer set to the issuer of the mock to try logging in with an identity provider.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"wishlist-backend/services/authentication/mockidp"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9090", "the address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9090", "the URL the provider is reachable at")
+	flag.Parse()
+
+	provider, err := mockidp.New(*issuer)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("serving a mock identity provider at " + *issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returned when the data of a session is claimed that belongs to an account.
+var errNotAnonymous = errors.New("the session belongs to an account")
+
+// The cookie that holds the hash of the state of a login with the identity provider, so only the
+// browser that started a login can finish it.
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/account/oidc"
+)
+
+type AccountController struct {
+	api *api
+}
+
+func (a *api) NewAccountController() *AccountController {
+	return &AccountController{api: a}
+}
+
+func (controller *AccountController) Init(router *gin.RouterGroup) {
+	router.GET("", controller.GetCurrentUser)
+	router.POST("/register", controller.Register)
+	router.POST("/login", controller.Login)
+	router.POST("/logout", controller.Logout)
+	router.POST("/refresh", controller.Refresh)
+	router.POST("/anonymous", controller.StartAnonymous)
+	router.POST("/exchange", controller.ExchangeLegacyKey)
+	router.GET("/oidc", controller.StartOIDCLogin)
+	router.POST("/oidc/callback", controller.FinishOIDCLogin)
+	router.GET("/keys", controller.GetApiKeys)
+	router.POST("/keys", controller.CreateApiKey)
+	router.DELETE("/keys/:key", controller.RevokeApiKey)
+	router.POST("/claim", controller.Claim)
+}
+
+type RegisterBody struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type LoginBody struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type ExchangeBody struct {
+	// A session key that was used as bearer token before accounts existed.
+	Key string `json:"key"`
+}
+
+type ClaimBody struct {
+	// The access token of an anonymous session.
+	AccessToken string `json:"accessToken"`
+	// A session key that was used as bearer token before accounts existed, used instead of an access token.
+	Key string `json:"key"`
+}
+
+type ApiKeyBody struct {
+	Name string `json:"name"`
+	// The space-separated scopes of the key.
+	Scopes string `json:"scopes"`
+}
+
+type OIDCCallbackBody struct {
+	// The code and state the identity provider sent the user back to the redirect URL with.
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// Creates an account and logs it in. The response contains the token of the new session.
+func (controller *AccountController) Register(c *gin.Context) {
+	body := RegisterBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	session, err := controller.api.auth.Register(body.Email, body.Name, body.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, authentication.ErrInvalidEmail),
+			errors.Is(err, authentication.ErrWeakPassword),
+			errors.Is(err, authentication.ErrLongPassword):
+			c.String(401, err.Error())
+		case errors.Is(err, repository.ErrAlreadyExists):
+			c.String(409, "An account with this email address already exists.")
+		default:
+			c.Error(err)
+			c.String(400, "Something went wrong")
+		}
+		return
+	}
+
+	c.IndentedJSON(201, session)
+}
+
+// Starts a login with the identity provider. The response contains the URL the user has to be sent to,
+// and sets a cookie that ties the login to the browser, which the callback requires.
+func (controller *AccountController) StartOIDCLogin(c *gin.Context) {
+	login, err := controller.api.auth.StartOIDCLogin()
+	if err != nil {
+		if errors.Is(err, authentication.ErrOIDCDisabled) {
+			c.String(404, "Not found")
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, login.StateHash, int(time.Until(login.ExpiresAt).Seconds()), oidcCookiePath, "", true, true)
+	c.IndentedJSON(200, gin.H{"authorizationUrl": login.AuthorizationURL})
+}
+
+// Finishes a login with the identity provider, creating an account for new users. The response
+// contains the token of the new session.
+func (controller *AccountController) FinishOIDCLogin(c *gin.Context) {
+	body := OIDCCallbackBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	// A missing cookie fails the login like an unknown state does.
+	stateHash, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", true, true)
+
+	session, err := controller.api.auth.FinishOIDCLogin(body.State, body.Code, stateHash)
+	if err != nil {
+		switch {
+		case errors.Is(err, authentication.ErrOIDCDisabled):
+			c.String(404, "Not found")
+		case errors.Is(err, authentication.ErrInvalidLoginAttempt):
+			c.String(401, "This login has expired, try again.")
+		case errors.Is(err, authentication.ErrInvalidIdToken),
+			errors.Is(err, authentication.ErrMissingEmail):
+			c.String(401, err.Error())
+		case errors.Is(err, authentication.ErrEmailTaken),
+			errors.Is(err, repository.ErrAlreadyExists):
+			c.String(409, "An account with this email address already exists, log in with your password.")
+		default:
+			c.Error(err)
+			c.String(400, "Something went wrong")
+		}
+		return
+	}
+
+	c.IndentedJSON(200, session)
+}
+
+// Logs a user in. The response contains the token of the new session.
+func (controller *AccountController) Login(c *gin.Context) {
+	body := LoginBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	session, err := controller.api.auth.Login(body.Email, body.Password)
+	if err != nil {
+		if errors.Is(err, authentication.ErrInvalidCredentials) {
+			c.String(401, "Invalid email or password.")
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, session)
+}
+
+// Ends the session the request was made with.
+func (controller *AccountController) Logout(c *gin.Context) {
+	sessionId := authentication.SessionId(c)
+	if len(sessionId) <= 0 {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	if err := controller.api.auth.Logout(sessionId); err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.Status(204)
+}
+
+// Replaces the session of a refresh token with a new session. Refresh tokens can only be used once.
+func (controller *AccountController) Refresh(c *gin.Context) {
+	body := RefreshBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	tokens, err := controller.api.auth.Refresh(body.RefreshToken)
+	controller.writeTokens(c, tokens, err, "Your session has expired, log in again.")
+}
+
+// Creates a session for a caller without an account.
+func (controller *AccountController) StartAnonymous(c *gin.Context) {
+	tokens, err := controller.api.auth.StartAnonymous()
+	controller.writeTokens(c, tokens, err, "")
+}
+
+// Exchanges a session key from before accounts existed for a session that owns the wishlists of that key.
+func (controller *AccountController) ExchangeLegacyKey(c *gin.Context) {
+	body := ExchangeBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	tokens, err := controller.api.auth.ExchangeLegacyKey(body.Key)
+	controller.writeTokens(c, tokens, err, "This key is unknown or was already exchanged.")
+}
+
+// Writes the tokens of a new session, or a 401 response with the message if the session does not exist.
+func (controller *AccountController) writeTokens(c *gin.Context, tokens *authentication.Tokens, err error, notFound string) {
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.String(401, notFound)
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(201, tokens)
+}
+
+// Returns the user that is logged in.
+func (controller *AccountController) GetCurrentUser(c *gin.Context) {
+	user := authentication.CurrentUser(c)
+	if user == nil {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	c.IndentedJSON(200, user)
+}
+
+// Returns the API keys of the user that is logged in.
+func (controller *AccountController) GetApiKeys(c *gin.Context) {
+	user := authentication.CurrentUser(c)
+	if user == nil {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	keys, err := controller.api.auth.GetApiKeys(user.Id)
+	if err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, keys)
+}
+
+// Creates an API key for the user that is logged in. The response is the only time the key itself is shown.
+func (controller *AccountController) CreateApiKey(c *gin.Context) {
+	user := authentication.CurrentUser(c)
+	if user == nil {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	body := ApiKeyBody{}
+	if err := c.BindJSON(&body); err != nil {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	key, err := controller.api.auth.CreateApiKey(user.Id, body.Name, body.Scopes)
+	if err != nil {
+		if errors.Is(err, authentication.ErrInvalidKeyName) || errors.Is(err, authentication.ErrInvalidScope) {
+			c.String(401, err.Error())
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(201, key)
+}
+
+// Revokes an API key of the user that is logged in.
+func (controller *AccountController) RevokeApiKey(c *gin.Context) {
+	user := authentication.CurrentUser(c)
+	if user == nil {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	if err := controller.api.auth.RevokeApiKey(user.Id, c.Param("key")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Moves the wishlists and permissions of an anonymous session, or of a session key from before
+// accounts existed, to the account that is logged in. The anonymous session is ended afterwards.
+func (controller *AccountController) Claim(c *gin.Context) {
+	user := authentication.CurrentUser(c)
+	if user == nil {
+		c.String(401, "You are not logged in.")
+		return
+	}
+
+	body := ClaimBody{}
+	if err := c.BindJSON(&body); err != nil || (len(body.AccessToken) > 0) == (len(body.Key) > 0) {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	var session *authentication.Claims
+	if len(body.AccessToken) > 0 {
+		claims, err := controller.api.auth.Verify(body.AccessToken)
+		if err != nil {
+			c.String(401, "This session has expired or is invalid.")
+			return
+		}
+		session = claims
+	}
+
+	var claimed *repository.Claimed
+	err := controller.api.repos.Transaction(func(repos *repository.Repositories) error {
+		var identity string
+		if session != nil {
+			identity = session.Subject
+		} else {
+			var err error
+			if identity, err = repos.Users.ExchangeLegacyKey(strings.ToLower(strings.TrimSpace(body.Key))); err != nil {
+				return err
+			}
+		}
+
+		// Accounts can not be claimed, only the data of anonymous callers.
+		_, err := repos.Users.GetUserById(identity)
+		if err == nil {
+			return errNotAnonymous
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		claimed, err = repos.Wishlists.ClaimOwnership(identity, user.Id)
+		return err
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errNotAnonymous):
+			c.String(403, "Only the data of anonymous sessions can be claimed.")
+		case errors.Is(err, os.ErrNotExist):
+			c.String(401, "This key is unknown or was already exchanged.")
+		default:
+			c.Error(err)
+			c.String(400, "Something went wrong")
+		}
+		return
+	}
+
+	if session != nil {
+		if err := controller.api.auth.Logout(session.SessionId); err != nil {
+			c.Error(err)
+		}
+	}
+
+	c.IndentedJSON(200, claimed)
+}
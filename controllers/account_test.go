@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
+	"wishlist-backend/services/authentication/mockidp"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	a := newTestAPI(t)
+	credentials := gin.H{"email": "ada@example.com", "password": "correct horse battery staple"}
+
+	tokens := authentication.Tokens{}
+	a.expect(t, 201, &tokens, "POST", "/account/register", "", credentials)
+	a.expect(t, 200, nil, "GET", "/account", tokens.AccessToken, nil)
+
+	a.expect(t, 200, &tokens, "POST", "/account/login", "", credentials)
+	a.expect(t, 401, nil, "POST", "/account/login", "", gin.H{"email": "ada@example.com", "password": "wrong"})
+	a.expect(t, 401, nil, "GET", "/account", "", nil)
+}
+
+func TestOIDCLoginNeedsStateCookie(t *testing.T) {
+	var provider *mockidp.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	provider, err := mockidp.New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos := repository.NewMemoryRepositories()
+	a := New(repos, authentication.New(repos.Users, authentication.Config{
+		Secret:          []byte("test secret"),
+		TokenLifetime:   time.Minute,
+		SessionLifetime: time.Hour,
+		OIDC: authentication.OIDCConfig{
+			Issuer:      server.URL,
+			ClientId:    "wishlist",
+			RedirectURL: "http://localhost:5173/login",
+		},
+	}))
+
+	started := a.request(t, "GET", "/account/oidc", "", nil)
+	if started.Code != 200 {
+		t.Fatalf("GET /account/oidc responded with %d: %s", started.Code, started.Body.String())
+	}
+
+	cookies := started.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly || cookies[0].MaxAge <= 0 {
+		t.Fatalf("cookies = %+v, want a single HttpOnly %s cookie that expires", cookies, oidcStateCookie)
+	}
+
+	login := struct {
+		AuthorizationUrl string `json:"authorizationUrl"`
+	}{}
+	if err := json.Unmarshal(started.Body.Bytes(), &login); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	response, err := client.Get(login.AuthorizationUrl + "&login_hint=" + url.QueryEscape("ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	redirect, err := response.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback := func(cookie *http.Cookie) int {
+		body, _ := json.Marshal(gin.H{"state": redirect.Query().Get("state"), "code": redirect.Query().Get("code")})
+		request := httptest.NewRequest("POST", "/account/oidc/callback", bytes.NewReader(body))
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+
+		recorder := httptest.NewRecorder()
+		a.httpClient.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if status := callback(nil); status != 401 {
+		t.Errorf("callback without the cookie responded with %d, want 401", status)
+	}
+
+	if status := callback(cookies[0]); status != 200 {
+		t.Errorf("callback with the cookie responded with %d, want 200", status)
+	}
+}
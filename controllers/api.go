@@ -2,37 +2,66 @@ package api
 
 import (
 	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
 
-	"github.com/doug-martin/goqu/v9"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 type api struct {
 	httpClient   *gin.Engine
-	itemRepo     *repository.ItemRepository
-	wishlistRepo *repository.WishlistRepository
+	repos        *repository.Repositories
+	itemRepo     repository.ItemStore
+	wishlistRepo repository.WishlistStore
+	auditRepo    repository.AuditStore
+	searchRepo   repository.SearchStore
+	auth         *authentication.Service
 }
 
-func New(db *goqu.Database) *api {
+// Creates the API on top of the given repositories. Use repository.NewSQLRepositories
+// to serve a database, or repository.NewMemoryRepositories to serve from memory.
+//
+// Every request is authenticated by the given service.
+func New(repos *repository.Repositories, auth *authentication.Service) *api {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:5173", "http://localhost:4000"}
+	config.AddAllowHeaders("Authorization")
+	// Logins with an identity provider are tied to the browser with a cookie.
+	config.AllowCredentials = true
 
 	httpClient := gin.Default()
+	// Without trusted proxies the client IP is the address of the peer, so clients can not pick
+	// their own IP with X-Forwarded-For and get around the limits that are kept per client.
+	httpClient.SetTrustedProxies(nil)
 	httpClient.Use(cors.New(config))
+	httpClient.Use(auth.Middleware())
+	httpClient.Use(requireScopes())
 
 	apiObj := &api{
 		httpClient:   httpClient,
-		itemRepo:     repository.NewItemRepository(db),
-		wishlistRepo: repository.NewWishlistRepository(db),
+		repos:        repos,
+		itemRepo:     repos.Items,
+		wishlistRepo: repos.Wishlists,
+		auditRepo:    repos.Audit,
+		searchRepo:   repos.Search,
+		auth:         auth,
 	}
 
 	apiObj.NewItemController().Init(httpClient.Group("/item"))
 	apiObj.NewWishlistController().Init(httpClient.Group("/wishlist"))
+	apiObj.NewInvitationController().Init(httpClient.Group("/wishlist/:id/invitations"))
+	apiObj.NewSearchController().Init(httpClient.Group("/search"))
+	apiObj.NewAccountController().Init(httpClient.Group("/account"))
 
 	return apiObj
 }
 
+// Trusts the X-Forwarded-For header of requests that come from one of the given proxies, which
+// are IP addresses or CIDR ranges. Only proxies that overwrite the header should be trusted.
+func (a *api) SetTrustedProxies(proxies []string) error {
+	return a.httpClient.SetTrustedProxies(proxies)
+}
+
 func (a *api) Run(url string) error {
 	return a.httpClient.Run(url)
 }
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// Creates the API on top of repositories that are kept in memory.
+func newTestAPI(t *testing.T) *api {
+	t.Helper()
+
+	repos := repository.NewMemoryRepositories()
+	auth := authentication.New(repos.Users, authentication.Config{
+		Secret:          []byte("test secret"),
+		TokenLifetime:   time.Minute,
+		SessionLifetime: time.Hour,
+	})
+	return New(repos, auth)
+}
+
+// Sends a request to the API as the holder of the access token, which may be empty. The body is
+// encoded as JSON unless it is nil.
+func (a *api) request(t *testing.T, method string, path string, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var content bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&content).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, &content)
+	if len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	a.httpClient.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// Sends a request to the API, fails the test if it does not respond with the expected status,
+// and decodes the response into result if it is not nil.
+func (a *api) expect(t *testing.T, status int, result interface{}, method string, path string, token string, body interface{}) {
+	t.Helper()
+
+	response := a.request(t, method, path, token, body)
+	if response.Code != status {
+		t.Fatalf("%s %s responded with %d, want %d: %s", method, path, response.Code, status, response.Body.String())
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s %s responded with invalid JSON: %v", method, path, err)
+		}
+	}
+}
+
+// Starts an anonymous session and returns its access token.
+func (a *api) anonymous(t *testing.T) string {
+	t.Helper()
+
+	tokens := authentication.Tokens{}
+	a.expect(t, 201, &tokens, "POST", "/account/anonymous", "", nil)
+	return tokens.AccessToken
+}
+
+// Creates a wishlist that is owned by the holder of the access token.
+func (a *api) addWishlist(t *testing.T, token string, name string) repository.Wishlist {
+	t.Helper()
+
+	wishlist := repository.Wishlist{}
+	a.expect(t, 201, &wishlist, "POST", "/wishlist", token, gin.H{"name": name})
+	return wishlist
+}
+
+// Serves a product page, so items can be added without reaching the internet.
+func newProductPage(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Bricks"></head></html>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"os"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The access a caller has to a wishlist and its items. Every level includes the levels below it.
+type access int
+
+const (
+	noAccess access = iota
+	// Viewers may see the wishlist and its items.
+	viewAccess
+	// Editors may change the wishlist and its items as well.
+	editAccess
+	// Only the owner may remove the wishlist and manage who has access to it.
+	ownerAccess
+)
+
+// The responses to callers that do not have the access they need.
+var forbidden = map[access]string{
+	viewAccess:  "You are not allowed to view this wishlist.",
+	editAccess:  "You are not allowed to edit this wishlist.",
+	ownerAccess: "Only the owner of this wishlist can do that.",
+}
+
+// Returns the access the holder of the session key has to the wishlist, either because they own it
+// or because they were given permissions.
+func (a *api) accessOf(wishlist *repository.Wishlist, key string) (access, error) {
+	if len(key) <= 0 {
+		return noAccess, nil
+	}
+
+	if wishlist.Ownership == key {
+		return ownerAccess, nil
+	}
+
+	permission, err := a.wishlistRepo.GetPermission(wishlist.Id, key)
+	if errors.Is(err, os.ErrNotExist) {
+		return noAccess, nil
+	}
+
+	if err != nil {
+		return noAccess, err
+	}
+
+	if permission == "EDIT" {
+		return editAccess, nil
+	}
+	return viewAccess, nil
+}
+
+// Verifies the caller has at least the needed access to the wishlist. Writes the error response
+// and returns false if that is not the case.
+func (a *api) authorize(c *gin.Context, wishlist *repository.Wishlist, needed access) bool {
+	key := getAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return false
+	}
+
+	granted, err := a.accessOf(wishlist, key)
+	if err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return false
+	}
+
+	if granted < needed {
+		c.String(403, forbidden[needed])
+		return false
+	}
+
+	return true
+}
+
+// The scope an API key needs for each endpoint it may be used on. API keys can not be used on the
+// other endpoints, like the ones that manage accounts, API keys or the wishlists themselves.
+var scopes = map[string]string{
+	"GET /wishlist":           authentication.ScopeReadWishlists,
+	"GET /wishlist/:id":       authentication.ScopeReadWishlists,
+	"GET /wishlist/:id/items": authentication.ScopeReadWishlists,
+	"GET /item":               authentication.ScopeReadWishlists,
+	"GET /item/:id":           authentication.ScopeReadWishlists,
+	"GET /search":             authentication.ScopeReadWishlists,
+
+	"POST /item/:id":         authentication.ScopeWriteItems,
+	"PUT /item":              authentication.ScopeWriteItems,
+	"PUT /item/:id":          authentication.ScopeWriteItems,
+	"DELETE /item/:id":       authentication.ScopeWriteItems,
+	"POST /item/:id/restore": authentication.ScopeWriteItems,
+	"POST /item/:id/refresh": authentication.ScopeWriteItems,
+
+	"PUT /wishlist/:id/items/order": authentication.ScopeWriteItems,
+
+	"GET /wishlist/:id/collaborators":                  authentication.ScopeManageCollaborators,
+	"PUT /wishlist/:id/collaborators/:collaborator":    authentication.ScopeManageCollaborators,
+	"DELETE /wishlist/:id/collaborators/:collaborator": authentication.ScopeManageCollaborators,
+	"GET /wishlist/:id/invitations":                    authentication.ScopeManageCollaborators,
+	"POST /wishlist/:id/invitations":                   authentication.ScopeManageCollaborators,
+	"DELETE /wishlist/:id/invitations/:invitation":     authentication.ScopeManageCollaborators,
+}
+
+// Rejects requests that are made with an API key that lacks the scope the endpoint needs.
+// Requests made with a session are not limited.
+func requireScopes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authentication.UsesApiKey(c) || len(c.FullPath()) <= 0 {
+			c.Next()
+			return
+		}
+
+		scope, ok := scopes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.String(403, "API keys can not be used for this endpoint.")
+			c.Abort()
+			return
+		}
+
+		if !authentication.HasScope(c, scope) {
+			c.String(403, "This API key needs the "+scope+" scope.")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +17,8 @@ type Controller[M interface{}, I any] interface {
 	GetById(*gin.Context)
 	Update(*gin.Context)
 	GetAll(*gin.Context)
+	Delete(*gin.Context)
+	Restore(*gin.Context)
 }
 
 // An abstract controller that fully implements the Controller[M, I] interface.
@@ -30,6 +34,13 @@ type AbstractController[M interface{}, I any] struct {
 	abstractRepo repository.Repository[M, I]
 	paramToId    func(string) (I, error)
 	empty        M
+	// Returns the wishlist the model belongs to, which determines who may see and change the model.
+	wishlistOf func(M) (*repository.Wishlist, error)
+	// The access that is needed to move the model to and out of the trash.
+	deleteAccess access
+	// Verifies the fields of a model that is added or updated, if the model needs more validation
+	// than decoding it does.
+	validate func(M) error
 }
 
 func (controller *AbstractController[M, I]) GetById(c *gin.Context) {
@@ -53,17 +64,39 @@ func (controller *AbstractController[M, I]) GetById(c *gin.Context) {
 		return
 	}
 
+	if !controller.authorize(c, *model, viewAccess) {
+		return
+	}
+
+	c.Header("ETag", controller.ETag(*model))
 	c.IndentedJSON(200, model)
 }
 
 func (controller *AbstractController[M, I]) GetAuthorization(c *gin.Context) string {
-	return strings.TrimSpace(strings.Replace(strings.ToLower(c.GetHeader("Authorization")), "bearer", "", 1))
+	return getAuthorization(c)
+}
+
+// Returns the identity of the caller, as resolved by the authentication middleware from the
+// Authorization header of the request.
+func getAuthorization(c *gin.Context) string {
+	return authentication.Identity(c)
 }
 
 func (controller *AbstractController[M, I]) GetAll(c *gin.Context) {
-	result, err := controller.abstractRepo.GetAll()
+	query, err := controller.ParseQuery(c)
+
+	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	result, err := controller.abstractRepo.GetAll(*query)
 
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			c.String(401, err.Error())
+			return
+		}
 		c.String(400, "Something went wrong")
 		c.Error(err)
 		return
@@ -78,8 +111,12 @@ func (controller *AbstractController[M, I]) Add(c *gin.Context) {
 		c.String(401, "Invalid body was provided.")
 		return
 	}
+
+	if !controller.valid(c, model) {
+		return
+	}
 	// controller.repo.RemoveId(&model)
-	result, err := controller.abstractRepo.Add(model)
+	result, err := controller.abstractRepo.Add(model, controller.GetAuthorization(c))
 
 	if err != nil {
 		c.String(400, err.Error())
@@ -98,6 +135,10 @@ func (controller *AbstractController[M, I]) Update(c *gin.Context) {
 		return
 	}
 
+	if !controller.valid(c, model) {
+		return
+	}
+
 	searchId := reflect.ValueOf(model).FieldByName("Id").Interface().(I)
 
 	if len(id) != 0 {
@@ -110,13 +151,203 @@ func (controller *AbstractController[M, I]) Update(c *gin.Context) {
 		}
 	}
 
-	if err := controller.abstractRepo.Update(model, &searchId); err != nil {
+	stored, err := controller.abstractRepo.GetById(searchId)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.authorize(c, *stored, editAccess) {
+		return
+	}
+
+	// The If-Match header takes precedent over the version in the body. A wildcard
+	// updates the model regardless of its version.
+	if ifMatch := c.GetHeader("If-Match"); len(ifMatch) > 0 {
+		version := 0
+		if ifMatch != "*" {
+			var err error
+			version, err = strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+
+			if err != nil {
+				c.String(401, "If-Match is not in the right format")
+				return
+			}
+		}
+		reflect.ValueOf(&model).Elem().FieldByName("Version").SetInt(int64(version))
+	}
+
+	result, err := controller.abstractRepo.Update(model, &searchId, controller.GetAuthorization(c))
+
+	if err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			c.String(412, "Someone else changed this in the meantime, refresh and try again.")
+			return
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			c.String(404, "Not found")
+			return
+		}
 		c.Error(err)
 		c.String(400, "Something went wrong while updating the item in the database.")
 		return
 	}
 
-	c.IndentedJSON(201, model)
+	c.Header("ETag", controller.ETag(*result))
+	c.IndentedJSON(201, result)
+}
+
+// Verifies the model is valid. Writes the error response and returns false if it is not.
+func (controller *AbstractController[M, I]) valid(c *gin.Context, model M) bool {
+	if controller.validate == nil {
+		return true
+	}
+
+	if err := controller.validate(model); err != nil {
+		c.String(401, err.Error())
+		return false
+	}
+	return true
+}
+
+// Returns the entity tag of the model, which changes whenever the model is updated.
+func (controller *AbstractController[M, I]) ETag(model M) string {
+	return `"` + strconv.Itoa(reflect.ValueOf(model).FieldByName("Version").Interface().(int)) + `"`
+}
+
+// Moves the model to the trash.
+func (controller *AbstractController[M, I]) Delete(c *gin.Context) {
+	id, err := controller.ValidateId(c.Param("id"))
+
+	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	model, err := controller.abstractRepo.GetById(*id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.authorize(c, *model, controller.deleteAccess) {
+		return
+	}
+
+	if err := controller.abstractRepo.DeleteById(*id, controller.GetAuthorization(c)); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Moves the model out of the trash.
+func (controller *AbstractController[M, I]) Restore(c *gin.Context) {
+	id, err := controller.ValidateId(c.Param("id"))
+
+	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	model, err := controller.abstractRepo.GetDeletedById(*id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.authorize(c, *model, controller.deleteAccess) {
+		return
+	}
+
+	if err := controller.abstractRepo.Restore(*id, controller.GetAuthorization(c)); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	controller.GetById(c)
+}
+
+// Verifies the caller has at least the needed access to the wishlist of the model. Writes the error
+// response and returns false if that is not the case.
+func (controller *AbstractController[M, I]) authorize(c *gin.Context, model M, needed access) bool {
+	wishlist, err := controller.wishlistOf(model)
+	if err != nil {
+		controller.WriteError(c, err)
+		return false
+	}
+
+	return controller.api.authorize(c, wishlist, needed)
+}
+
+// Writes a 404 response if the error indicates a model does not exist, and a generic 400 response otherwise.
+func (controller *AbstractController[M, I]) WriteError(c *gin.Context, err error) {
+	writeError(c, err)
+}
+
+// Writes a 404 response if the error indicates a model does not exist, and a generic 400 response otherwise.
+func writeError(c *gin.Context, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		c.String(404, "Not found")
+		c.Error(os.ErrNotExist)
+		return
+	}
+	c.Error(err)
+	c.String(400, "Something went wrong")
+}
+
+// Parses the pagination, sorting and filtering options from the query parameters of the request.
+//
+// Supports `limit` and `cursor` for pagination, and a comma separated list of fields in `sort`.
+// Fields prefixed with a '-' are sorted in descending order.
+//
+// Every other query parameter is a filter on the field with that name. The operator of the filter
+// is determined by the last character of the name, e.g. `name~=lego` for a case-insensitive search.
+func (controller *AbstractController[M, I]) ParseQuery(c *gin.Context) (*repository.Query, error) {
+	query := &repository.Query{}
+
+	for key, values := range c.Request.URL.Query() {
+		for _, value := range values {
+			switch key {
+			case "limit":
+				limit, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, errors.New("limit is not a number")
+				}
+				query.Limit = limit
+			case "cursor":
+				query.Cursor = value
+			case "sort":
+				for _, field := range strings.Split(value, ",") {
+					field, descending := strings.CutPrefix(strings.TrimSpace(field), "-")
+					query.Sort = append(query.Sort, repository.Sort{Field: field, Descending: descending})
+				}
+			default:
+				query.Filters = append(query.Filters, parseFilter(key, value))
+			}
+		}
+	}
+
+	return query, nil
+}
+
+// Parses a filter from a query parameter. The operator is part of the key, since the
+// parameter `name~=lego` has `name~` as its key.
+func parseFilter(key string, value string) repository.Filter {
+	operators := map[string]repository.Operator{
+		"!": repository.NotEqual,
+		"~": repository.Contains,
+		">": repository.GreaterOrEqual,
+		"<": repository.LessOrEqual,
+	}
+
+	for suffix, operator := range operators {
+		if field, ok := strings.CutSuffix(key, suffix); ok {
+			return repository.Filter{Field: field, Operator: operator, Value: value}
+		}
+	}
+	return repository.Filter{Field: key, Operator: repository.Equal, Value: value}
 }
 
 func (controller *AbstractController[M, I]) ValidateId(id string) (*I, error) {
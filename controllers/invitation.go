@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"time"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// How long an invitation lasts when no expiry is given.
+	defaultInvitationLifetime = 7 * 24 * time.Hour
+	// The longest an invitation can last.
+	maxInvitationLifetime = 90 * 24 * time.Hour
+)
+
+type InvitationController struct {
+	api *api
+}
+
+func (a *api) NewInvitationController() *InvitationController {
+	return &InvitationController{api: a}
+}
+
+func (controller *InvitationController) Init(router *gin.RouterGroup) {
+	router.GET("", controller.GetInvitations)
+	router.POST("", controller.Add)
+	router.DELETE("/:invitation", controller.Delete)
+	router.POST("/redeem", controller.Redeem)
+}
+
+type InvitationBody struct {
+	// The permissions the invitation gives, either VIEW or EDIT.
+	Permission string `json:"permission"`
+	// The moment the invitation expires. Defaults to a week from now.
+	ExpiresAt *time.Time `json:"expiresAt"`
+	// The amount of times the invitation can be redeemed, or 0 if there is no limit.
+	MaxRedemptions int `json:"maxRedemptions"`
+}
+
+type RedeemBody struct {
+	Token string `json:"token"`
+}
+
+// Creates an invitation to the wishlist. The response contains the token of the invitation, which
+// can not be retrieved later. Only the owner of the wishlist may do this.
+func (controller *InvitationController) Add(c *gin.Context) {
+	wishlist, ok := controller.authorizeOwner(c)
+	if !ok {
+		return
+	}
+
+	body := InvitationBody{}
+	if err := c.BindJSON(&body); err != nil || (body.Permission != "VIEW" && body.Permission != "EDIT") || body.MaxRedemptions < 0 {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(defaultInvitationLifetime)
+	if body.ExpiresAt != nil {
+		expiresAt = *body.ExpiresAt
+	}
+
+	if !expiresAt.After(now) || expiresAt.After(now.Add(maxInvitationLifetime)) {
+		c.String(401, "Invitations have to expire within 90 days.")
+		return
+	}
+
+	invitation, err := controller.api.repos.Invitations.AddInvitation(repository.Invitation{
+		WishlistId:     wishlist.Id,
+		Permissions:    body.Permission,
+		ExpiresAt:      expiresAt,
+		MaxRedemptions: body.MaxRedemptions,
+	})
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.IndentedJSON(201, invitation)
+}
+
+// Returns the invitations to the wishlist that can still be redeemed. Only the owner of the wishlist may see them.
+func (controller *InvitationController) GetInvitations(c *gin.Context) {
+	wishlist, ok := controller.authorizeOwner(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := controller.api.repos.Invitations.GetInvitations(wishlist.Id, time.Now())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, invitations)
+}
+
+// Revokes an invitation to the wishlist. Viewers that already redeemed it keep their permissions.
+// Only the owner of the wishlist may do this.
+func (controller *InvitationController) Delete(c *gin.Context) {
+	wishlist, ok := controller.authorizeOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.api.repos.Invitations.DeleteInvitation(wishlist.Id, c.Param("invitation")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Redeems an invitation to the wishlist, which gives the caller the permissions of the invitation.
+// The token is sent in the body, so it does not end up in logs and browser history.
+func (controller *InvitationController) Redeem(c *gin.Context) {
+	key := getAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	body := RedeemBody{}
+	if err := c.BindJSON(&body); err != nil || len(body.Token) <= 0 {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	wishlist, err := controller.api.wishlistRepo.GetById(c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// The owner already has every permission, so their invitations are not used up.
+	if wishlist.Ownership != key {
+		_, err := controller.api.repos.Invitations.RedeemInvitation(wishlist.Id, body.Token, key, time.Now())
+		if errors.Is(err, repository.ErrInvalidInvitation) {
+			c.String(403, "This invitation is invalid or has expired.")
+			return
+		}
+
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+
+	granted, err := controller.api.accessOf(wishlist, key)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	permission := "VIEW"
+	if granted >= editAccess {
+		permission = "EDIT"
+	}
+
+	c.IndentedJSON(200, repository.WishlistPermissioned{
+		Wishlist:    *wishlist,
+		Permissions: permission,
+	})
+}
+
+// Looks up the wishlist in the path and verifies the caller owns it. Writes the error response and
+// returns false if that is not the case.
+func (controller *InvitationController) authorizeOwner(c *gin.Context) (*repository.Wishlist, bool) {
+	wishlist, err := controller.api.wishlistRepo.GetById(c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return nil, false
+	}
+
+	if !controller.api.authorize(c, wishlist, ownerAccess) {
+		return nil, false
+	}
+
+	return wishlist, true
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedeemInvitation(t *testing.T) {
+	a := newTestAPI(t)
+	owner, guest, other := a.anonymous(t), a.anonymous(t), a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday")
+	path := "/wishlist/" + wishlist.Id + "/invitations"
+
+	a.expect(t, 403, nil, "POST", path, guest, gin.H{"permission": "VIEW"})
+
+	invitation := repository.Invitation{}
+	a.expect(t, 201, &invitation, "POST", path, owner, gin.H{"permission": "VIEW", "maxRedemptions": 1})
+
+	permissioned := repository.WishlistPermissioned{}
+	a.expect(t, 200, &permissioned, "POST", path+"/redeem", guest, gin.H{"token": invitation.Token})
+	if permissioned.Permissions != "VIEW" {
+		t.Errorf("permission = %q, want VIEW", permissioned.Permissions)
+	}
+
+	a.expect(t, 200, nil, "GET", "/wishlist/"+wishlist.Id, guest, nil)
+
+	// Opening the invitation again does not use it up.
+	a.expect(t, 200, nil, "POST", path+"/redeem", guest, gin.H{"token": invitation.Token})
+
+	invitations := []repository.Invitation{}
+	a.expect(t, 200, &invitations, "GET", path, owner, nil)
+	if len(invitations) != 0 {
+		t.Errorf("invitations = %+v after the only redemption, want none", invitations)
+	}
+
+	a.expect(t, 403, nil, "POST", path+"/redeem", other, gin.H{"token": invitation.Token})
+}
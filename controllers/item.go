@@ -1,6 +1,8 @@
 package api
 
 import (
+	"errors"
+	"slices"
 	repository "wishlist-backend/repositories"
 	"wishlist-backend/services/ogp"
 
@@ -18,6 +20,11 @@ func (a *api) NewItemController() *ItemController {
 			abstractRepo: a.itemRepo,
 			paramToId:    func(s string) (string, error) { return s, nil },
 			empty:        repository.Item{},
+			wishlistOf: func(item repository.Item) (*repository.Wishlist, error) {
+				return a.wishlistRepo.GetById(item.WishlistId)
+			},
+			deleteAccess: editAccess,
+			validate:     repository.Item.Validate,
 		},
 	}
 }
@@ -28,38 +35,55 @@ func (controller *ItemController) Init(router *gin.RouterGroup) {
 	// router.GET("/:id/embed", controller.GetEmbed)
 	router.PUT("/:id", controller.Update)
 	router.PUT("", controller.Update)
-	router.POST("/:wishlistId", controller.Add)
+	// The ID of this route is the ID of the wishlist the item is added to.
+	router.POST("/:id", controller.Add)
+	router.DELETE("/:id", controller.Delete)
+	router.POST("/:id/restore", controller.Restore)
+	router.POST("/:id/refresh", controller.Refresh)
+	router.POST("/:id/reservation", controller.Reserve)
+	router.DELETE("/:id/reservation", controller.CancelReservation)
+	router.POST("/:id/pledge", controller.Pledge)
+	router.DELETE("/:id/pledge", controller.WithdrawPledge)
 }
 
 func (controller *ItemController) Add(c *gin.Context) {
 
 	// DANGEROUS, IF WISHLIST ID TYPE CHANGES, THIS WILL BREAK
-	id, err := controller.ValidateId(c.Param("wishlistId"))
+	id, err := controller.ValidateId(c.Param("id"))
 	if err != nil {
 		c.String(401, "An invalid ID was provided.")
 		return
 	}
 
+	wishlist, err := controller.api.wishlistRepo.GetById(*id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, editAccess) {
+		return
+	}
+
 	model := controller.empty
 	if err := c.BindJSON(&model); err != nil {
 		c.String(401, "Invalid body was provided.")
 		return
 	}
 
-	data, err := ogp.GetOGPData(model.Url)
-	if err != nil {
+	if !controller.valid(c, model) {
+		return
+	}
+
+	if err := scrape(&model); err != nil {
 		c.String(401, "Invalid URL was provided.")
 		return
 	}
 
 	model.WishlistId = *id
-	model.Description = data.Description
-	model.Image = data.Image
-	model.Name = data.Title
-	model.Url = data.Url
 
 	// controller.repo.RemoveId(&model)
-	result, err := controller.abstractRepo.Add(model)
+	result, err := controller.abstractRepo.Add(model, controller.GetAuthorization(c))
 
 	if err != nil {
 		c.String(400, err.Error())
@@ -68,3 +92,106 @@ func (controller *ItemController) Add(c *gin.Context) {
 
 	c.IndentedJSON(201, result)
 }
+
+// Returns the items of the wishlists the caller owns or was given permissions to.
+func (controller *ItemController) GetAll(c *gin.Context) {
+	key := controller.GetAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	query, err := controller.ParseQuery(c)
+	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	items, err := controller.api.wishlistRepo.GetAccessibleItems(key, *query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			c.String(401, err.Error())
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	// Reservations are only left out on the items of the wishlists of the caller.
+	owned, err := controller.api.wishlistRepo.GetOwnedWishlists(key)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	data, err := controller.api.withReservations(items.Data, key, func(item repository.Item) bool {
+		return slices.ContainsFunc(owned, func(wishlist repository.Wishlist) bool {
+			return wishlist.Id == item.WishlistId
+		})
+	})
+
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, repository.Page[ReservedItem]{Data: data, Pagination: items.Pagination})
+}
+
+// Scrapes the details of the item from its URL again, for example when the page changed since the
+// item was added. The fields the owner controls, like the priority, notes and price, are kept.
+func (controller *ItemController) Refresh(c *gin.Context) {
+	item, err := controller.abstractRepo.GetById(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.authorize(c, *item, editAccess) {
+		return
+	}
+
+	if err := scrape(item); err != nil {
+		c.String(401, "Invalid URL was provided.")
+		return
+	}
+
+	if !controller.valid(c, *item) {
+		return
+	}
+
+	result, err := controller.abstractRepo.Update(*item, &item.Id, controller.GetAuthorization(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			c.String(412, "Someone else changed this in the meantime, refresh and try again.")
+			return
+		}
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", controller.ETag(*result))
+	c.IndentedJSON(200, result)
+}
+
+// Fills in the details of the item using the Open Graph data of its URL. Only the fields that come
+// from the page are changed. The price of the page is only used when the item has no price yet, so
+// a price the owner entered takes precedence, and the currency of pledges to the item stays the same.
+func scrape(item *repository.Item) error {
+	data, err := ogp.GetOGPData(item.Url)
+	if err != nil {
+		return err
+	}
+
+	item.Description = data.Description
+	item.Image = data.Image
+	item.Name = data.Title
+	item.Url = data.Url
+
+	if price, err := repository.ParseAmount(data.PriceAmount); err == nil && item.Price == nil {
+		item.Price = &price
+		item.Currency = data.PriceCurrency
+	}
+	return nil
+}
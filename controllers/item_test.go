@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddItem(t *testing.T) {
+	a := newTestAPI(t)
+	page := newProductPage(t)
+	owner, stranger := a.anonymous(t), a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday")
+
+	item := repository.Item{}
+	a.expect(t, 201, &item, "POST", "/item/"+wishlist.Id, owner, gin.H{"url": page.URL, "quantity": 2})
+	if item.Name != "Bricks" || item.Quantity != 2 {
+		t.Errorf("item = %q with quantity %d, want %q with quantity 2", item.Name, item.Quantity, "Bricks")
+	}
+
+	a.expect(t, 200, nil, "GET", "/item/"+item.Id, owner, nil)
+	a.expect(t, 403, nil, "POST", "/item/"+wishlist.Id, stranger, gin.H{"url": page.URL, "quantity": 1})
+	a.expect(t, 401, nil, "POST", "/item/"+wishlist.Id, owner, gin.H{"url": "not a url", "quantity": 1})
+}
+
+func TestItemPermissions(t *testing.T) {
+	a := newTestAPI(t)
+	page := newProductPage(t)
+	owner, viewer, editor, stranger := a.anonymous(t), a.anonymous(t), a.anonymous(t), a.anonymous(t)
+
+	wishlist := repository.UnlockedWishlist{}
+	a.expect(t, 201, &wishlist, "POST", "/wishlist", owner, gin.H{"name": "Birthday"})
+
+	item := repository.Item{}
+	a.expect(t, 201, &item, "POST", "/item/"+wishlist.Id, owner, gin.H{"url": page.URL, "quantity": 1})
+
+	a.expect(t, 200, nil, "POST", "/wishlist/"+wishlist.Id+"/permission", viewer, nil)
+	a.expect(t, 200, nil, "POST", "/wishlist/"+wishlist.Id+"/permission/"+wishlist.Password, editor, nil)
+
+	// Viewers can see the items, but only editors can change them.
+	a.expect(t, 200, nil, "GET", "/item/"+item.Id, viewer, nil)
+	a.expect(t, 403, nil, "PUT", "/item/"+item.Id, viewer, gin.H{"name": "Red bricks", "url": page.URL, "quantity": 1})
+	a.expect(t, 403, nil, "DELETE", "/item/"+item.Id, viewer, nil)
+	a.expect(t, 201, nil, "PUT", "/item/"+item.Id, editor, gin.H{"name": "Red bricks", "url": page.URL, "quantity": 1})
+
+	a.expect(t, 403, nil, "GET", "/item/"+item.Id, stranger, nil)
+	a.expect(t, 403, nil, "DELETE", "/item/"+item.Id, stranger, nil)
+}
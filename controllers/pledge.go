@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The maximum length of the message of a pledge.
+const maxPledgeMessageLength = 500
+
+type PledgeBody struct {
+	Amount repository.Amount `json:"amount"`
+	// The currency of the amount, the currency of the item if it is left out.
+	Currency string `json:"currency"`
+	Message  string `json:"message"`
+}
+
+// A pledge as the guests of a wishlist see it.
+type PledgeView struct {
+	repository.Pledge
+	// A pseudonym of the guest that made the pledge, which matches their ID as a collaborator.
+	Contributor string `json:"contributor"`
+	// The name of the guest, if they are logged in to an account.
+	Name string `json:"name,omitempty"`
+	// Whether the caller made the pledge.
+	Mine bool `json:"mine"`
+}
+
+// How far the guests funded the price of an item.
+type Funding struct {
+	// The amount that is pledged, and the amount that is left to fund, in the currency of the item.
+	Pledged   repository.Amount `json:"pledged"`
+	Remaining repository.Amount `json:"remaining"`
+	// Whether the pledges cover the full price of the item.
+	FullyFunded bool         `json:"fullyFunded"`
+	Pledges     []PledgeView `json:"pledges"`
+	// The pledges in another currency than the item, made before the owner changed its currency.
+	// They do not count towards its price.
+	OtherPledges []PledgeView `json:"otherPledges,omitempty"`
+}
+
+// Pledges an amount towards the price of the item for the caller, replacing the pledge they already
+// made. Guests can not pledge more than is left to fund, and owners can not pledge to their own items.
+func (controller *ItemController) Pledge(c *gin.Context) {
+	item, ok := controller.findReservable(c)
+	if !ok {
+		return
+	}
+
+	body := PledgeBody{}
+	if err := c.BindJSON(&body); err != nil || body.Amount <= 0 || len([]rune(body.Message)) > maxPledgeMessageLength {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	pledge, err := controller.api.repos.Pledges.Pledge(repository.Pledge{
+		ItemId:      item.Id,
+		Contributor: controller.GetAuthorization(c),
+		Amount:      body.Amount,
+		Currency:    strings.ToUpper(strings.TrimSpace(body.Currency)),
+		Message:     body.Message,
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNoPrice):
+			c.String(409, "This item has no price to contribute to.")
+		case errors.Is(err, repository.ErrCurrencyMismatch):
+			c.String(409, "Pledges to this item need to be made in "+item.Currency+".")
+		case errors.Is(err, repository.ErrOverPledged):
+			c.String(409, "This pledge is more than is left to fund.")
+		default:
+			controller.WriteError(c, err)
+		}
+		return
+	}
+
+	view, err := controller.api.toPledgeView(*pledge, controller.GetAuthorization(c))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, view)
+}
+
+// Withdraws the pledge of the caller.
+func (controller *ItemController) WithdrawPledge(c *gin.Context) {
+	item, ok := controller.findReservable(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.api.repos.Pledges.WithdrawPledge(item.Id, controller.GetAuthorization(c)); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Totals the pledges that were made to the item, which needs to have a price.
+func (a *api) toFunding(item repository.Item, pledges []repository.Pledge, key string) (*Funding, error) {
+	funding := &Funding{Pledges: []PledgeView{}}
+	for _, pledge := range pledges {
+		if pledge.ItemId != item.Id {
+			continue
+		}
+
+		view, err := a.toPledgeView(pledge, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if pledge.Currency != item.Currency {
+			funding.OtherPledges = append(funding.OtherPledges, *view)
+			continue
+		}
+
+		funding.Pledged += pledge.Amount
+		funding.Pledges = append(funding.Pledges, *view)
+	}
+
+	// The owner can lower the price after guests pledged to it.
+	funding.Remaining = max(*item.Price-funding.Pledged, 0)
+	funding.FullyFunded = funding.Remaining <= 0
+	return funding, nil
+}
+
+// Returns the pledge as the caller sees it.
+func (a *api) toPledgeView(pledge repository.Pledge, key string) (*PledgeView, error) {
+	name, err := a.nameOf(pledge.Contributor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PledgeView{
+		Pledge:      pledge,
+		Contributor: pseudonym(pledge.Contributor),
+		Name:        name,
+		Mine:        pledge.Contributor == key,
+	}, nil
+}
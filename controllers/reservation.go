@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"os"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The maximum length of the note of a reservation.
+const maxReservationNoteLength = 500
+
+type ReservationBody struct {
+	// The amount of units to reserve, 1 if it is left out.
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note"`
+}
+
+// A reservation as the guests of a wishlist see it.
+type ReservationView struct {
+	repository.Reservation
+	// A pseudonym of the guest that reserved the item, which matches their ID as a collaborator.
+	Reserver string `json:"reserver"`
+	// The name of the guest, if they are logged in to an account.
+	Name string `json:"name,omitempty"`
+	// Whether the caller made the reservation.
+	Mine bool `json:"mine"`
+}
+
+// An item with its reservations and pledges. Both are left out for the owner of the wishlist,
+// so their gifts stay a surprise.
+type ReservedItem struct {
+	repository.Item
+	// The amount of units that are reserved.
+	Reserved     *int              `json:"reserved,omitempty"`
+	Reservations []ReservationView `json:"reservations,omitempty"`
+	// How far the guests funded the price of the item. Left out for items without a price.
+	Funding *Funding `json:"funding,omitempty"`
+}
+
+// Reserves units of the item for the caller, replacing the reservation they already had. Guests
+// can not reserve more units than the owner wants, and owners can not reserve their own items.
+func (controller *ItemController) Reserve(c *gin.Context) {
+	item, ok := controller.findReservable(c)
+	if !ok {
+		return
+	}
+
+	body := ReservationBody{}
+	if err := c.BindJSON(&body); err != nil || body.Quantity < 0 || len([]rune(body.Note)) > maxReservationNoteLength {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	reservation, err := controller.api.repos.Reservations.Reserve(repository.Reservation{
+		ItemId:   item.Id,
+		Reserver: controller.GetAuthorization(c),
+		Quantity: max(body.Quantity, 1),
+		Note:     body.Note,
+	})
+
+	if err != nil {
+		if errors.Is(err, repository.ErrOverReserved) {
+			c.String(409, "Not enough of this item is left to reserve.")
+			return
+		}
+		controller.WriteError(c, err)
+		return
+	}
+
+	view, err := controller.api.toReservationView(*reservation, controller.GetAuthorization(c))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, view)
+}
+
+// Cancels the reservation of the caller.
+func (controller *ItemController) CancelReservation(c *gin.Context) {
+	item, ok := controller.findReservable(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.api.repos.Reservations.CancelReservation(item.Id, controller.GetAuthorization(c)); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Looks up the item in the path and verifies the caller may reserve it or pledge to it, which every
+// viewer of its wishlist except the owner may do. Writes the error response and returns false if that is not the case.
+func (controller *ItemController) findReservable(c *gin.Context) (*repository.Item, bool) {
+	item, err := controller.abstractRepo.GetById(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return nil, false
+	}
+
+	wishlist, err := controller.wishlistOf(*item)
+	if err != nil {
+		controller.WriteError(c, err)
+		return nil, false
+	}
+
+	if !controller.api.authorize(c, wishlist, viewAccess) {
+		return nil, false
+	}
+
+	if wishlist.Ownership == controller.GetAuthorization(c) {
+		c.String(403, "You can not reserve or contribute to items on your own wishlist.")
+		return nil, false
+	}
+
+	return item, true
+}
+
+// Returns the item with its reservations, unless the caller owns the wishlist of the item.
+func (controller *ItemController) GetById(c *gin.Context) {
+	item, err := controller.abstractRepo.GetById(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	wishlist, err := controller.wishlistOf(*item)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, viewAccess) {
+		return
+	}
+
+	key := controller.GetAuthorization(c)
+	items, err := controller.api.withReservations([]repository.Item{*item}, key, func(repository.Item) bool {
+		return wishlist.Ownership == key
+	})
+
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", controller.ETag(*item))
+	c.IndentedJSON(200, items[0])
+}
+
+// Adds the reservations and pledges to the items, except to those for which owned reports that the
+// caller owns their wishlist.
+func (a *api) withReservations(items []repository.Item, key string, owned func(repository.Item) bool) ([]ReservedItem, error) {
+	reserved := make([]ReservedItem, len(items))
+	visible := []string{}
+	for i, item := range items {
+		reserved[i].Item = item
+		if !owned(item) {
+			visible = append(visible, item.Id)
+			reserved[i].Reserved = new(int)
+		}
+	}
+
+	reservations, err := a.repos.Reservations.GetReservations(visible)
+	if err != nil {
+		return nil, err
+	}
+
+	pledges, err := a.repos.Pledges.GetPledges(visible)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range reserved {
+		if reserved[i].Reserved == nil {
+			continue
+		}
+
+		for _, reservation := range reservations {
+			if reservation.ItemId != reserved[i].Id {
+				continue
+			}
+
+			view, err := a.toReservationView(reservation, key)
+			if err != nil {
+				return nil, err
+			}
+
+			*reserved[i].Reserved += reservation.Quantity
+			reserved[i].Reservations = append(reserved[i].Reservations, *view)
+		}
+
+		if reserved[i].Price != nil {
+			if reserved[i].Funding, err = a.toFunding(reserved[i].Item, pledges, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return reserved, nil
+}
+
+// Returns the reservation as the caller sees it.
+func (a *api) toReservationView(reservation repository.Reservation, key string) (*ReservationView, error) {
+	name, err := a.nameOf(reservation.Reserver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReservationView{
+		Reservation: reservation,
+		Reserver:    pseudonym(reservation.Reserver),
+		Name:        name,
+		Mine:        reservation.Reserver == key,
+	}, nil
+}
+
+// Returns the name of the account of the identity, or an empty name for anonymous callers.
+func (a *api) nameOf(identity string) (string, error) {
+	user, err := a.repos.Users.GetUserById(identity)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	api *api
+}
+
+func (a *api) NewSearchController() *SearchController {
+	return &SearchController{api: a}
+}
+
+func (controller *SearchController) Init(router *gin.RouterGroup) {
+	router.GET("", controller.Search)
+}
+
+// Searches the wishlists the caller owns or has access to, and their items. The query is given
+// by the `q` query parameter, the maximum amount of results by `limit`.
+func (controller *SearchController) Search(c *gin.Context) {
+	key := getAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	query := c.Query("q")
+	if len(query) <= 0 {
+		c.String(401, "No search query was provided.")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.String(401, "limit is not a number")
+		return
+	}
+
+	results, err := controller.api.searchRepo.Search(key, query, limit)
+	if err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, results)
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"testing"
+	repository "wishlist-backend/repositories"
+)
+
+func TestSearch(t *testing.T) {
+	a := newTestAPI(t)
+	owner, stranger := a.anonymous(t), a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday presents")
+
+	results := []repository.SearchResult{}
+	a.expect(t, 200, &results, "GET", "/search?q=birth", owner, nil)
+	if len(results) != 1 || results[0].Id != wishlist.Id {
+		t.Errorf("results = %+v, want the wishlist", results)
+	}
+
+	a.expect(t, 200, &results, "GET", "/search?q=birth", stranger, nil)
+	if len(results) != 0 {
+		t.Errorf("results = %+v for someone without access, want none", results)
+	}
+
+	a.expect(t, 401, nil, "GET", "/search", owner, nil)
+}
@@ -1,14 +1,24 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"time"
 	repository "wishlist-backend/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
+// The maximum amount of changes that can be undone or redone at once.
+const maxUndoSteps = 50
+
 type WishlistController struct {
 	*AbstractController[repository.Wishlist, string]
-	repo *repository.WishlistRepository
+	repo repository.WishlistStore
 }
 
 func (a *api) NewWishlistController() *WishlistController {
@@ -19,20 +29,36 @@ func (a *api) NewWishlistController() *WishlistController {
 			abstractRepo: a.wishlistRepo,
 			paramToId:    func(s string) (string, error) { return s, nil },
 			empty:        repository.Wishlist{},
+			wishlistOf: func(wishlist repository.Wishlist) (*repository.Wishlist, error) {
+				return &wishlist, nil
+			},
+			deleteAccess: ownerAccess,
 		},
 	}
 }
 
 func (controller *WishlistController) Init(router *gin.RouterGroup) {
 	// router.GET("/admin", controller.GetAll)
+	router.GET("/trash", controller.GetTrash)
 	router.GET("/:id", controller.GetById)
 	router.GET("/:id/items", controller.GetItems)
+	router.PUT("/:id/items/order", controller.ReorderItems)
+	router.GET("/:id/history", controller.GetHistory)
+	router.POST("/:id/undo", controller.Undo)
+	router.POST("/:id/redo", controller.Redo)
 	router.POST("", controller.Add)
 	router.PUT("/:id", controller.Update)
 	router.PUT("", controller.Update)
 	router.GET("", controller.GetAccessibleWishlists)
+	router.DELETE("/:id", controller.Delete)
+	router.POST("/:id/restore", controller.Restore)
 	router.POST("/:id/permission", controller.RegisterPermission)
 	router.POST("/:id/permission/:password", controller.RegisterPermission)
+	router.POST("/:id/password/rotate", controller.RotatePassword)
+	router.GET("/:id/password/attempts", controller.GetPasswordAttempts)
+	router.GET("/:id/collaborators", controller.GetCollaborators)
+	router.PUT("/:id/collaborators/:collaborator", controller.UpdateCollaborator)
+	router.DELETE("/:id/collaborators/:collaborator", controller.RemoveCollaborator)
 }
 
 func (controller *WishlistController) Add(c *gin.Context) {
@@ -48,6 +74,17 @@ func (controller *WishlistController) Add(c *gin.Context) {
 		return
 	}
 
+	items := make([]repository.Item, len(model.Items))
+	for i, url := range model.Items {
+		items[i].Url = url
+		items[i].Quantity = 1
+		items[i].Priority = repository.PriorityNiceToHave
+		if err := scrape(&items[i]); err != nil {
+			c.String(401, "Invalid URL was provided.")
+			return
+		}
+	}
+
 	wishlist := repository.Wishlist{
 		Model: repository.Model[string]{
 			Id: model.Id,
@@ -55,7 +92,24 @@ func (controller *WishlistController) Add(c *gin.Context) {
 		Name:      model.Name,
 		Ownership: key,
 	}
-	result, err := controller.abstractRepo.Add(wishlist)
+
+	// The wishlist is only created if all of its items can be added as well.
+	var result *repository.Wishlist
+	err := controller.api.repos.Transaction(func(repos *repository.Repositories) error {
+		var err error
+		result, err = repos.Wishlists.Add(wishlist, key)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			item.WishlistId = result.Id
+			if _, err := repos.Items.Add(item, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 
 	if err != nil {
 		c.String(400, err.Error())
@@ -78,15 +132,84 @@ func (controller *WishlistController) GetItems(c *gin.Context) {
 		return
 	}
 
-	items, err := controller.repo.GetItems(*id)
+	wishlist, err := controller.repo.GetById(*id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, viewAccess) {
+		return
+	}
+
+	query, err := controller.ParseQuery(c)
 
 	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	items, err := controller.repo.GetItems(*id, *query)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			c.String(401, err.Error())
+			return
+		}
 		c.String(500, "Something went wrong on the server.")
 		c.Error(err)
 		return
 	}
 
-	c.IndentedJSON(200, items)
+	key := controller.GetAuthorization(c)
+	data, err := controller.api.withReservations(items.Data, key, func(repository.Item) bool {
+		return wishlist.Ownership == key
+	})
+
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, repository.Page[ReservedItem]{Data: data, Pagination: items.Pagination})
+}
+
+type ItemOrderBody struct {
+	// The IDs of the items in their new order. Items that are left out keep their order.
+	Items []string `json:"items"`
+	// The ID of the item the items are placed after. They are placed first when it is left out.
+	After string `json:"after"`
+}
+
+// Changes the order of the items of the wishlist. Both the full order and a few items that move
+// can be sent, only the positions of the items that move are changed.
+func (controller *WishlistController) ReorderItems(c *gin.Context) {
+	wishlist, err := controller.repo.GetById(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, editAccess) {
+		return
+	}
+
+	body := ItemOrderBody{}
+	if err := c.BindJSON(&body); err != nil || len(body.Items) <= 0 {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	if err := controller.api.repos.Items.Reorder(wishlist.Id, body.Items, body.After); err != nil {
+		if errors.Is(err, repository.ErrInvalidOrder) {
+			c.String(401, err.Error())
+			return
+		}
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Status(204)
 }
 
 func (controller *WishlistController) GetAccessibleWishlists(c *gin.Context) {
@@ -125,12 +248,461 @@ func (controller *WishlistController) RegisterPermission(c *gin.Context) {
 		return
 	}
 
-	err := controller.repo.RegisterPermission(c.Param("id"), key, c.Param("password"))
+	password := c.Param("password")
+	if len(password) <= 0 {
+		if err := controller.repo.RegisterPermission(c.Param("id"), key, password); err != nil {
+			controller.WriteError(c, err)
+			return
+		}
+
+		c.String(200, "OK")
+		return
+	}
+
+	// Guessing passwords is slowed down by locking out the wishlist and the client after too many
+	// failures. The lockout is checked and the failure counted in a single transaction, so concurrent
+	// guesses are counted one after another.
+	var until time.Time
+	var wrong bool
+	err := controller.api.repos.Transaction(func(repos *repository.Repositories) error {
+		var err error
+		if until, err = repos.PasswordAttempts.LockThrottles(c.Param("id"), c.ClientIP()); err != nil {
+			return err
+		}
+
+		if until.After(time.Now()) {
+			return nil
+		}
+
+		err = repos.Wishlists.RegisterPermission(c.Param("id"), key, password)
+		if errors.Is(err, repository.ErrInvalidPassword) {
+			wrong = true
+			until, err = repos.PasswordAttempts.AddFailedAttempt(repository.FailedPasswordAttempt{
+				WishlistId: c.Param("id"),
+				Ownership:  key,
+				ClientIp:   c.ClientIP(),
+				CreatedAt:  time.Now(),
+			})
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+		return repos.PasswordAttempts.ResetFailures(c.Param("id"))
+	})
 
 	if err != nil {
-		c.String(500, "Something went wrong.")
+		controller.WriteError(c, err)
+		return
+	}
+
+	if until.After(time.Now()) {
+		writeLockedOut(c, until)
+		return
+	}
+
+	if wrong {
+		c.String(403, "The password of this wishlist is incorrect.")
 		return
 	}
 
 	c.String(200, "OK")
 }
+
+// Writes a 429 response, which tells the client to wait until the lockout ends before trying another password.
+func writeLockedOut(c *gin.Context, until time.Time) {
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.String(429, "Too many wrong passwords were tried, try again in "+strconv.Itoa(seconds)+" seconds.")
+}
+
+type FailedPasswordAttempt struct {
+	// A pseudonym of the caller that made the attempt, which matches their ID as a collaborator.
+	Client      string    `json:"client"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+type PasswordAttempts struct {
+	// Until when nobody can try a password for the wishlist, if it is locked out.
+	LockedUntil *time.Time              `json:"lockedUntil,omitempty"`
+	Attempts    []FailedPasswordAttempt `json:"attempts"`
+}
+
+// Returns the latest attempts to get edit permissions with a wrong password, and whether the
+// wishlist is locked out because of them. Only the owner of the wishlist may see them.
+func (controller *WishlistController) GetPasswordAttempts(c *gin.Context) {
+	wishlist, ok := controller.authorizeOwner(c, controller.repo.GetById)
+	if !ok {
+		return
+	}
+
+	attempts := controller.api.repos.PasswordAttempts
+	failed, err := attempts.GetFailedAttempts(wishlist.Id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	// Only the lockout of the wishlist itself is relevant to the owner, not that of their own client.
+	until, err := attempts.GetLockout(wishlist.Id, "")
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	response := PasswordAttempts{Attempts: make([]FailedPasswordAttempt, len(failed))}
+	if until.After(time.Now()) {
+		response.LockedUntil = &until
+	}
+
+	for i, attempt := range failed {
+		response.Attempts[i] = FailedPasswordAttempt{
+			Client:      pseudonym(attempt.Ownership),
+			AttemptedAt: attempt.CreatedAt,
+		}
+	}
+
+	c.IndentedJSON(200, response)
+}
+
+// Replaces the password of the wishlist, so links with the old password no longer give edit
+// permissions. Only the owner of the wishlist may do this. With `?demote=true`, viewers that
+// already have edit permissions lose them as well.
+func (controller *WishlistController) RotatePassword(c *gin.Context) {
+	if _, ok := controller.authorizeOwner(c, controller.repo.GetById); !ok {
+		return
+	}
+
+	demote, err := strconv.ParseBool(c.DefaultQuery("demote", "false"))
+	if err != nil {
+		c.String(401, "Invalid demote parameter was provided.")
+		return
+	}
+
+	wishlist, err := controller.repo.RotatePassword(c.Param("id"), demote)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, repository.UnlockedWishlist{
+		Model:     wishlist.Model,
+		Name:      wishlist.Name,
+		Password:  wishlist.Password,
+		Ownership: wishlist.Ownership,
+	})
+}
+
+// Returns the wishlists and items in the trash of the caller.
+func (controller *WishlistController) GetTrash(c *gin.Context) {
+	key := controller.GetAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	wishlists, err := controller.repo.GetDeletedWishlists(key)
+	if err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	items, err := controller.repo.GetDeletedItems(key)
+	if err != nil {
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{
+		"wishlists": wishlists,
+		"items":     items,
+	})
+}
+
+// A viewer of a wishlist, as it is shown to the owner. Collaborators are identified by the
+// same pseudonym as in the history of the wishlist.
+type Collaborator struct {
+	Id string `json:"id"`
+	// The name of the collaborator, if they are logged in to an account.
+	Name       string    `json:"name,omitempty"`
+	Permission string    `json:"permission"`
+	JoinedAt   time.Time `json:"joinedAt"`
+}
+
+type CollaboratorBody struct {
+	Permission string `json:"permission"`
+}
+
+// Returns the viewers of the wishlist with their permissions. Only the owner of the wishlist may see them.
+func (controller *WishlistController) GetCollaborators(c *gin.Context) {
+	wishlist, ok := controller.authorizeOwner(c, controller.repo.GetById)
+	if !ok {
+		return
+	}
+
+	viewers, err := controller.repo.GetViewers(wishlist.Id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	collaborators := make([]Collaborator, len(viewers))
+	for i, viewer := range viewers {
+		collaborator, err := controller.toCollaborator(viewer)
+		if err != nil {
+			controller.WriteError(c, err)
+			return
+		}
+		collaborators[i] = *collaborator
+	}
+
+	c.IndentedJSON(200, collaborators)
+}
+
+// Changes the permissions of a viewer of the wishlist, for example to take away their edit
+// permissions. Only the owner of the wishlist may do this.
+func (controller *WishlistController) UpdateCollaborator(c *gin.Context) {
+	wishlist, viewer, ok := controller.findCollaborator(c)
+	if !ok {
+		return
+	}
+
+	body := CollaboratorBody{}
+	if err := c.BindJSON(&body); err != nil || (body.Permission != "VIEW" && body.Permission != "EDIT") {
+		c.String(401, "Invalid body was provided.")
+		return
+	}
+
+	if err := controller.repo.SetPermission(wishlist.Id, viewer.Ownership, body.Permission); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	viewer.Permissions = body.Permission
+	collaborator, err := controller.toCollaborator(*viewer)
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.IndentedJSON(200, collaborator)
+}
+
+// Revokes all permissions of a viewer of the wishlist. They can open the wishlist again if they
+// still know its link. Only the owner of the wishlist may do this.
+func (controller *WishlistController) RemoveCollaborator(c *gin.Context) {
+	wishlist, viewer, ok := controller.findCollaborator(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.repo.RemoveViewer(wishlist.Id, viewer.Ownership); err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Returns the viewer as it is shown to the owner of the wishlist.
+func (controller *WishlistController) toCollaborator(viewer repository.WishlistViewer) (*Collaborator, error) {
+	collaborator := &Collaborator{
+		Id:         pseudonym(viewer.Ownership),
+		Permission: viewer.Permissions,
+		JoinedAt:   viewer.CreatedAt,
+	}
+
+	user, err := controller.api.repos.Users.GetUserById(viewer.Ownership)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if user != nil {
+		collaborator.Name = user.Name
+	}
+
+	return collaborator, nil
+}
+
+// Looks up the wishlist and the viewer with the pseudonym in the path, and verifies the caller owns
+// the wishlist. Writes the error response and returns false if either can not be found.
+func (controller *WishlistController) findCollaborator(c *gin.Context) (*repository.Wishlist, *repository.WishlistViewer, bool) {
+	wishlist, ok := controller.authorizeOwner(c, controller.repo.GetById)
+	if !ok {
+		return nil, nil, false
+	}
+
+	viewers, err := controller.repo.GetViewers(wishlist.Id)
+	if err != nil {
+		controller.WriteError(c, err)
+		return nil, nil, false
+	}
+
+	for _, viewer := range viewers {
+		if pseudonym(viewer.Ownership) == c.Param("collaborator") {
+			return wishlist, &viewer, true
+		}
+	}
+
+	controller.WriteError(c, os.ErrNotExist)
+	return nil, nil, false
+}
+
+// A change in the history of a wishlist, as it is shown to its viewers.
+type HistoryEntry struct {
+	repository.AuditEntry
+	Actor HistoryActor `json:"actor"`
+}
+
+// Whoever made a change. Session keys are secret, so actors are identified by a pseudonym
+// that is the same for every change they made.
+type HistoryActor struct {
+	Id    string `json:"id"`
+	Self  bool   `json:"self"`
+	Owner bool   `json:"owner"`
+}
+
+// Returns the changes made to the wishlist and its items, newest first unless sorted otherwise.
+// Only the owner and viewers of the wishlist may see its history.
+func (controller *WishlistController) GetHistory(c *gin.Context) {
+	key := controller.GetAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	wishlist, err := controller.repo.GetById(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, viewAccess) {
+		return
+	}
+
+	query, err := controller.ParseQuery(c)
+	if err != nil {
+		c.String(401, err.Error())
+		return
+	}
+
+	if len(query.Sort) <= 0 {
+		query.Sort = []repository.Sort{{Field: "createdAt", Descending: true}}
+	}
+
+	history, err := controller.api.auditRepo.GetHistory(wishlist.Id, *query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			c.String(401, err.Error())
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, repository.Page[HistoryEntry]{
+		Data:       toHistory(history.Data, wishlist, key),
+		Pagination: history.Pagination,
+	})
+}
+
+// Reverts the last changes the caller made to the wishlist and its items. The amount of changes
+// is given by the `steps` query parameter, which defaults to 1.
+func (controller *WishlistController) Undo(c *gin.Context) {
+	controller.replay(c, controller.api.auditRepo.Undo)
+}
+
+// Applies the last changes the caller undid to the wishlist and its items again. The amount of
+// changes is given by the `steps` query parameter, which defaults to 1.
+func (controller *WishlistController) Redo(c *gin.Context) {
+	controller.replay(c, controller.api.auditRepo.Redo)
+}
+
+func (controller *WishlistController) replay(c *gin.Context, replay func(string, string, int) ([]repository.AuditEntry, error)) {
+	key := controller.GetAuthorization(c)
+	if len(key) <= 0 {
+		c.String(400, "You don't have a session key associated with your browser")
+		return
+	}
+
+	steps, err := strconv.Atoi(c.DefaultQuery("steps", "1"))
+	if err != nil || steps < 1 || steps > maxUndoSteps {
+		c.String(401, "steps has to be a number between 1 and "+strconv.Itoa(maxUndoSteps))
+		return
+	}
+
+	// Undoing the removal of a wishlist restores it, so wishlists in the trash can be undone as well.
+	wishlist, err := controller.repo.GetById(c.Param("id"))
+	if errors.Is(err, os.ErrNotExist) {
+		wishlist, err = controller.repo.GetDeletedById(c.Param("id"))
+	}
+
+	if err != nil {
+		controller.WriteError(c, err)
+		return
+	}
+
+	if !controller.api.authorize(c, wishlist, editAccess) {
+		return
+	}
+
+	changes, err := replay(wishlist.Id, key, steps)
+	if err != nil {
+		if errors.Is(err, repository.ErrNothingToUndo) {
+			c.String(409, "There are no changes left to undo or redo.")
+			return
+		}
+		if errors.Is(err, repository.ErrVersionConflict) {
+			c.String(409, "Someone else changed this in the meantime, so it can't be undone or redone.")
+			return
+		}
+		c.Error(err)
+		c.String(400, "Something went wrong")
+		return
+	}
+
+	c.IndentedJSON(200, toHistory(changes, wishlist, key))
+}
+
+// Returns the history entries of the changes to the wishlist, as seen by the holder of the session key.
+func toHistory(changes []repository.AuditEntry, wishlist *repository.Wishlist, key string) []HistoryEntry {
+	entries := make([]HistoryEntry, len(changes))
+	for i, entry := range changes {
+		entries[i] = HistoryEntry{
+			AuditEntry: entry,
+			Actor: HistoryActor{
+				Id:    pseudonym(entry.Actor),
+				Self:  entry.Actor == key,
+				Owner: entry.Actor == wishlist.Ownership,
+			},
+		}
+	}
+	return entries
+}
+
+// Returns a short identifier of the session key, that does not reveal the key itself.
+func pseudonym(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:6])
+}
+
+// Looks up the wishlist in the path using the given getter and verifies the caller owns it.
+// Writes the error response and returns false if that is not the case.
+func (controller *WishlistController) authorizeOwner(c *gin.Context, get func(string) (*repository.Wishlist, error)) (*repository.Wishlist, bool) {
+	wishlist, err := get(c.Param("id"))
+	if err != nil {
+		controller.WriteError(c, err)
+		return nil, false
+	}
+
+	if !controller.api.authorize(c, wishlist, ownerAccess) {
+		return nil, false
+	}
+
+	return wishlist, true
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUndoIsAllOrNothing(t *testing.T) {
+	a := newTestAPI(t)
+	page := newProductPage(t)
+	owner, collaborator := a.anonymous(t), a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday")
+
+	item := repository.Item{}
+	a.expect(t, 201, &item, "POST", "/item/"+wishlist.Id, owner, gin.H{"name": "Bricks", "url": page.URL, "quantity": 1})
+	a.expect(t, 201, nil, "PUT", "/item/"+item.Id, owner, gin.H{"name": "Red bricks", "url": page.URL, "quantity": 1})
+	a.expect(t, 201, nil, "PUT", "/wishlist/"+wishlist.Id, owner, gin.H{"name": "Christmas"})
+
+	invitation := repository.Invitation{}
+	a.expect(t, 201, &invitation, "POST", "/wishlist/"+wishlist.Id+"/invitations", owner, gin.H{"permission": "EDIT"})
+	a.expect(t, 200, nil, "POST", "/wishlist/"+wishlist.Id+"/invitations/redeem", collaborator, gin.H{"token": invitation.Token})
+	a.expect(t, 201, nil, "PUT", "/item/"+item.Id, collaborator, gin.H{"name": "Blue bricks", "url": page.URL, "quantity": 1})
+
+	// Renaming the wishlist can be undone, but the item was changed by the collaborator since.
+	a.expect(t, 409, nil, "POST", "/wishlist/"+wishlist.Id+"/undo?steps=2", owner, nil)
+
+	stored := repository.Wishlist{}
+	a.expect(t, 200, &stored, "GET", "/wishlist/"+wishlist.Id, owner, nil)
+	if stored.Name != "Christmas" {
+		t.Errorf("name = %q after a failed undo, want %q", stored.Name, "Christmas")
+	}
+
+	history := []gin.H{}
+	a.expect(t, 200, &history, "POST", "/wishlist/"+wishlist.Id+"/undo", owner, nil)
+	if len(history) != 1 || history[0]["entity"] != "Wishlist" {
+		t.Errorf("undo = %+v, want the rename of the wishlist", history)
+	}
+}
+
+func TestPasswordLockoutSurvivesOtherUnlock(t *testing.T) {
+	a := newTestAPI(t)
+	owner, guesser := a.anonymous(t), a.anonymous(t)
+	target := a.addWishlist(t, owner, "Birthday")
+
+	own := repository.UnlockedWishlist{}
+	a.expect(t, 201, &own, "POST", "/wishlist", guesser, gin.H{"name": "Decoy"})
+
+	for range 5 {
+		a.expect(t, 403, nil, "POST", "/wishlist/"+target.Id+"/permission/wrong", guesser, nil)
+	}
+
+	// Entering the correct password of another wishlist does not give the client more guesses.
+	a.expect(t, 200, nil, "POST", "/wishlist/"+own.Id+"/permission/"+own.Password, guesser, nil)
+	a.expect(t, 429, nil, "POST", "/wishlist/"+target.Id+"/permission/wrong", guesser, nil)
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	a := newTestAPI(t)
+	owner := a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday")
+
+	etag := a.request(t, "GET", "/wishlist/"+wishlist.Id, owner, nil).Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %s, want \"1\"", etag)
+	}
+
+	update := func(ifMatch string, name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(gin.H{"name": name})
+		request := httptest.NewRequest("PUT", "/wishlist/"+wishlist.Id, bytes.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+owner)
+		request.Header.Set("If-Match", ifMatch)
+
+		recorder := httptest.NewRecorder()
+		a.httpClient.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if response := update(etag, "Christmas"); response.Code != 201 || response.Header().Get("ETag") != `"2"` {
+		t.Fatalf("updating the current version responded with %d and ETag %s, want 201 and \"2\"", response.Code, response.Header().Get("ETag"))
+	}
+
+	// The wishlist was changed since the first version was retrieved.
+	if response := update(etag, "Easter"); response.Code != 412 {
+		t.Errorf("updating an old version responded with %d, want 412", response.Code)
+	}
+
+	if response := update("*", "Easter"); response.Code != 201 {
+		t.Errorf("updating any version responded with %d, want 201", response.Code)
+	}
+}
+
+func TestForwardedForNeedsTrustedProxy(t *testing.T) {
+	guess := func(a *api, token string, wishlistId string, forwardedFor string) int {
+		request := httptest.NewRequest("POST", "/wishlist/"+wishlistId+"/permission/wrong", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+
+		recorder := httptest.NewRecorder()
+		a.httpClient.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Clients can not pretend to be someone else to get more guesses.
+	a := newTestAPI(t)
+	owner, guesser := a.anonymous(t), a.anonymous(t)
+	wishlist := a.addWishlist(t, owner, "Birthday")
+
+	for i := range 5 {
+		guess(a, guesser, wishlist.Id, fmt.Sprint("198.51.100.", i))
+	}
+
+	if status := guess(a, guesser, wishlist.Id, "198.51.100.99"); status != 429 {
+		t.Errorf("guess with a forged address responded with %d, want 429", status)
+	}
+
+	// Behind a trusted proxy, the clients it forwards for are told apart.
+	a = newTestAPI(t)
+	if err := a.SetTrustedProxies([]string{"192.0.2.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+
+	owner, guesser = a.anonymous(t), a.anonymous(t)
+	wishlist = a.addWishlist(t, owner, "Birthday")
+
+	for range 5 {
+		guess(a, guesser, wishlist.Id, "198.51.100.1")
+	}
+
+	if status := guess(a, guesser, wishlist.Id, "198.51.100.2"); status != 403 {
+		t.Errorf("guess of another client behind the proxy responded with %d, want 403", status)
+	}
+}
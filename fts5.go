@@ -0,0 +1,7 @@
+//go:build sqlite_fts5 || fts5
+
+package main
+
+func init() {
+	sqliteFTS5 = true
+}
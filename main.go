@@ -1,24 +1,145 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 	api "wishlist-backend/controllers"
+	"wishlist-backend/migrations"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication"
+	"wishlist-backend/services/purge"
 
 	"github.com/doug-martin/goqu/v9"
-	_ "github.com/doug-martin/goqu/v9/dialect/sqlite3"
 
 	// _ "modernc.org/sqlite"
-	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/lib/pq"
 )
 
+// Whether the SQLite driver was built with FTS5, which the search index uses. Without it, searches
+// scan the wishlists and items instead. Build with `-tags sqlite_fts5` to enable it.
+var sqliteFTS5 = false
+
 func main() {
-	sqlite, err := sql.Open("sqlite3", "file:test.db?_foreign_keys=on")
+	driver := flag.String("driver", getEnv("DATABASE_DRIVER", "sqlite3"), "the database to connect to, either 'sqlite3' or 'postgres'")
+	dsn := flag.String("dsn", getEnv("DATABASE_URL", "file:test.db?_foreign_keys=on"), "the data source name of the database")
+	migrate := flag.String("migrate", "", "migrate the database and exit. Accepts 'up' for the latest version, 'down' to roll back a single version, or a version number")
+	retention := flag.Duration("trash-retention", 30*24*time.Hour, "how long deleted wishlists and items stay in the trash before they are removed permanently")
+	sessionLifetime := flag.Duration("session-lifetime", 30*24*time.Hour, "how long users stay logged in without using their refresh token")
+	tokenLifetime := flag.Duration("token-lifetime", 15*time.Minute, "how long an access token can be used before it has to be refreshed")
+	tokenSecret := flag.String("token-secret", getEnv("TOKEN_SECRET", ""), "the key access tokens are signed with. A random key is used when empty, which invalidates access tokens on every restart")
+	oidcIssuer := flag.String("oidc-issuer", getEnv("OIDC_ISSUER", ""), "the URL of the OpenID Connect provider users can log in with. Login with an identity provider is disabled when empty")
+	oidcClientId := flag.String("oidc-client-id", getEnv("OIDC_CLIENT_ID", ""), "the client ID that is registered at the OpenID Connect provider")
+	oidcClientSecret := flag.String("oidc-client-secret", getEnv("OIDC_CLIENT_SECRET", ""), "the client secret that is registered at the OpenID Connect provider")
+	oidcRedirectURL := flag.String("oidc-redirect-url", getEnv("OIDC_REDIRECT_URL", ""), "the page the OpenID Connect provider sends users back to after they logged in")
+	trustedProxies := flag.String("trusted-proxies", getEnv("TRUSTED_PROXIES", ""), "comma-separated IP addresses or CIDR ranges of the proxies whose X-Forwarded-For header is trusted. No proxies are trusted when empty")
+	flag.Parse()
+
+	if *driver != "sqlite3" && *driver != "postgres" {
+		log.Fatal("unsupported database driver " + *driver)
+	}
+
+	driverName := *driver
+	if driverName == "sqlite3" {
+		driverName = repository.SQLiteDriver
+	}
 
-	db := goqu.New("sqlite3", sqlite)
+	conn, err := sql.Open(driverName, *dsn)
 
 	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The driver names match the names of their goqu dialects.
+	db := goqu.New(*driver, conn)
+
+	// Without FTS5, the search index is created as a regular table, which searches scan.
+	fallbacks := []string{}
+	if *driver == "sqlite3" && !sqliteFTS5 {
+		log.Println("the sqlite3 driver was built without full-text search, searches scan every wishlist instead. Build with -tags sqlite_fts5 to enable it")
+		if err := checkSearchIndex(db); err != nil {
+			log.Fatal(err)
+		}
+		fallbacks = append(fallbacks, migrations.NoFTS5)
+	}
+
+	if len(*migrate) > 0 {
+		if err := runMigration(db, *migrate, fallbacks); err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
-	api.New(db).Run("localhost:8000")
+	if err := migrations.Latest(db, fallbacks...); err != nil {
+		log.Fatal(err)
+	}
+
+	repos := repository.NewSQLRepositories(db)
+
+	stopPurge := purge.Schedule(time.Hour, *retention, repos.Items, repos.Wishlists, repos.Users, repos.Invitations, repos.PasswordAttempts)
+	defer stopPurge()
+
+	secret := []byte(*tokenSecret)
+	if len(secret) <= 0 {
+		log.Println("no token secret was configured, using a random one")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	auth := authentication.New(repos.Users, authentication.Config{
+		Secret:          secret,
+		TokenLifetime:   *tokenLifetime,
+		SessionLifetime: *sessionLifetime,
+		OIDC: authentication.OIDCConfig{
+			Issuer:       *oidcIssuer,
+			ClientId:     *oidcClientId,
+			ClientSecret: *oidcClientSecret,
+			RedirectURL:  *oidcRedirectURL,
+		},
+	})
+
+	server := api.New(repos, auth)
+	if len(*trustedProxies) > 0 {
+		proxies := strings.Split(*trustedProxies, ",")
+		for i := range proxies {
+			proxies[i] = strings.TrimSpace(proxies[i])
+		}
+
+		if err := server.SetTrustedProxies(proxies); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server.Run("localhost:8000")
+}
+
+// Migrates the database as instructed by the migrate flag.
+func runMigration(db *goqu.Database, migrate string, fallbacks []string) error {
+	switch migrate {
+	case "up":
+		return migrations.Latest(db, fallbacks...)
+	case "down":
+		return migrations.Rollback(db, 1, fallbacks...)
+	}
+
+	version, err := strconv.Atoi(migrate)
+	if err != nil {
+		return err
+	}
+	return migrations.Migrate(db, version, fallbacks...)
+}
+
+// Returns the value of the environment variable, or the fallback if it is not set.
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
 }
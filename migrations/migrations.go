@@ -0,0 +1,280 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// The SQL files of every supported dialect, stored in a directory named after the
+// goqu dialect they are written for.
+//
+// Files should be named `<version>_<name>.up.sql` and `<version>_<name>.down.sql`. Fallbacks
+// for migrations are kept in subdirectories of the dialect, see Load.
+//
+//go:embed sqlite3 postgres
+var files embed.FS
+
+// The name of the table that keeps track of the applied migrations.
+const versionTable = "schema_version"
+
+// A single versioned change to the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	// The SQL that applies this migration.
+	Up string
+	// The SQL that reverts this migration.
+	Down string
+}
+
+// A row of the schema version table.
+type appliedMigration struct {
+	Version   int       `db:"Version"`
+	Name      string    `db:"Name"`
+	AppliedAt time.Time `db:"AppliedAt"`
+}
+
+// Replaces the migrations that need FTS5 on SQLite, for drivers that were built without it.
+const NoFTS5 = "nofts5"
+
+// Returns all migrations for the given dialect, sorted by version.
+//
+// Fallbacks name subdirectories of the dialect with migrations that replace those of the same
+// version, for databases that lack a feature the regular migrations need. The same fallbacks have
+// to be used every time a database is migrated.
+//
+// May return an error if the dialect or a fallback is not supported, or if a migration is missing
+// either its up or its down file.
+func Load(dialect string, fallbacks ...string) ([]Migration, error) {
+	migrations, err := readMigrations(dialect)
+	if err != nil {
+		return nil, errors.New("no migrations available for dialect " + dialect)
+	}
+
+	for _, fallback := range fallbacks {
+		replacements, err := readMigrations(path.Join(dialect, fallback))
+		if err != nil {
+			return nil, errors.New("no fallback " + fallback + " available for dialect " + dialect)
+		}
+
+		for version, migration := range replacements {
+			if _, ok := migrations[version]; !ok {
+				return nil, fmt.Errorf("fallback %s replaces migration %d, which does not exist", fallback, version)
+			}
+			migrations[version] = migration
+		}
+	}
+
+	sorted := []Migration{}
+	for _, migration := range migrations {
+		if len(migration.Up) <= 0 || len(migration.Down) <= 0 {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", migration.Version)
+		}
+		sorted = append(sorted, *migration)
+	}
+
+	slices.SortFunc(sorted, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+
+	return sorted, nil
+}
+
+// Reads the migrations in the directory by their version. Subdirectories are skipped.
+func readMigrations(dir string) (map[int]*Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := map[int]*Migration{}
+	for _, entry := range entries {
+		name, isUp := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !isUp {
+			var isDown bool
+			if name, isDown = strings.CutSuffix(entry.Name(), ".down.sql"); !isDown {
+				continue
+			}
+		}
+
+		versionString, name, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionString)
+		if err != nil {
+			return nil, errors.New("migration " + entry.Name() + " does not start with a version number")
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrations[version] = migration
+		}
+
+		if isUp {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	return migrations, nil
+}
+
+// Returns the version of the most recently applied migration, or 0 if no migrations were applied.
+func Version(db *goqu.Database) (int, error) {
+	if err := createVersionTable(db); err != nil {
+		return 0, err
+	}
+
+	var version int
+	_, err := db.From(versionTable).Select(goqu.COALESCE(goqu.MAX("Version"), 0)).ScanVal(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Applies all migrations that have not been applied yet.
+func Latest(db *goqu.Database, fallbacks ...string) error {
+	migrations, err := Load(db.Dialect(), fallbacks...)
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) <= 0 {
+		return nil
+	}
+	return Migrate(db, migrations[len(migrations)-1].Version, fallbacks...)
+}
+
+// Reverts the given amount of most recently applied migrations.
+//
+// Returns an error if steps is not positive, or if the database was migrated to a version that
+// does not exist, for example by a newer version of the application.
+func Rollback(db *goqu.Database, steps int, fallbacks ...string) error {
+	if steps <= 0 {
+		return fmt.Errorf("can not roll back %d migrations", steps)
+	}
+
+	migrations, err := Load(db.Dialect(), fallbacks...)
+	if err != nil {
+		return err
+	}
+
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	if current == 0 {
+		return nil
+	}
+
+	index := slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == current })
+	if index < 0 {
+		return fmt.Errorf("the database is at version %d, which is not a known migration", current)
+	}
+
+	target := 0
+	if index-steps >= 0 {
+		target = migrations[index-steps].Version
+	}
+
+	return Migrate(db, target, fallbacks...)
+}
+
+// Migrates the database forwards or backwards until the given version is reached.
+// A version of 0 reverts every migration.
+//
+// Every migration is run in its own transaction. When a migration fails, the migrations
+// before it stay applied. Returns an error if the database was migrated to a version that does
+// not exist.
+func Migrate(db *goqu.Database, target int, fallbacks ...string) error {
+	migrations, err := Load(db.Dialect(), fallbacks...)
+	if err != nil {
+		return err
+	}
+
+	if target != 0 && !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == target }) {
+		return fmt.Errorf("migration %d does not exist", target)
+	}
+
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	if current != 0 && !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == current }) {
+		return fmt.Errorf("the database is at version %d, which is not a known migration", current)
+	}
+
+	if target >= current {
+		for _, migration := range migrations {
+			if migration.Version > current && migration.Version <= target {
+				if err := apply(db, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	slices.Reverse(migrations)
+	for _, migration := range migrations {
+		if migration.Version <= current && migration.Version > target {
+			if err := revert(db, migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Runs the up SQL of the migration and registers it in the version table.
+func apply(db *goqu.Database, migration Migration) error {
+	return db.WithTx(func(tx *goqu.TxDatabase) error {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return fmt.Errorf("applying migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		_, err := tx.Insert(versionTable).Rows(appliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().UTC(),
+		}).Executor().Exec()
+		return err
+	})
+}
+
+// Runs the down SQL of the migration and removes it from the version table.
+func revert(db *goqu.Database, migration Migration) error {
+	return db.WithTx(func(tx *goqu.TxDatabase) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("reverting migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		_, err := tx.Delete(versionTable).Where(goqu.C("Version").Eq(migration.Version)).Executor().Exec()
+		return err
+	})
+}
+
+func createVersionTable(db *goqu.Database) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS "` + versionTable + `" (
+		"Version"   INTEGER PRIMARY KEY,
+		"Name"      TEXT NOT NULL,
+		"AppliedAt" TIMESTAMP NOT NULL
+	)`)
+	return err
+}
@@ -0,0 +1,118 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	repository "wishlist-backend/repositories"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Opens a new SQLite database in a temporary directory.
+func openSQLite(t *testing.T) *goqu.Database {
+	t.Helper()
+
+	conn, err := sql.Open(repository.SQLiteDriver, "file:"+filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return goqu.New("sqlite3", conn)
+}
+
+// Returns the SQL that created the table, or an empty string if it does not exist.
+func tableSQL(t *testing.T, db *goqu.Database, table string) string {
+	t.Helper()
+
+	var statement string
+	if _, err := db.From("sqlite_master").Select("sql").Where(goqu.C("name").Eq(table)).ScanVal(&statement); err != nil {
+		t.Fatal(err)
+	}
+	return statement
+}
+
+func TestFallback(t *testing.T) {
+	if _, err := Load("sqlite3", "unknown"); err == nil {
+		t.Error("loading an unknown fallback succeeded")
+	}
+
+	db := openSQLite(t)
+	if err := Latest(db, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	if statement := tableSQL(t, db, "SearchIndex"); len(statement) <= 0 || strings.Contains(statement, "fts5") {
+		t.Errorf("search index was created as %q, want a regular table", statement)
+	}
+
+	// Reverting the fallback removes the table again.
+	if err := Migrate(db, 6, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	if statement := tableSQL(t, db, "SearchIndex"); len(statement) > 0 {
+		t.Errorf("search index is %q after reverting its migration, want none", statement)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := openSQLite(t)
+	if err := Latest(db, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := Load("sqlite3", NoFTS5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rollback(db, 0, NoFTS5); err == nil {
+		t.Error("rolling back 0 migrations succeeded")
+	}
+
+	if err := Rollback(db, 2, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err := Version(db); err != nil || version != migrations[len(migrations)-3].Version {
+		t.Errorf("version = %d after rolling back 2 migrations, want %d", version, migrations[len(migrations)-3].Version)
+	}
+
+	// Every migration can be reverted and applied again.
+	if err := Migrate(db, 0, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Latest(db, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollbackUnknownVersion(t *testing.T) {
+	db := openSQLite(t)
+	if err := Latest(db, NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer version of the application applied a migration this version does not know.
+	_, err := db.Insert(versionTable).Rows(appliedMigration{Version: 9999, Name: "unknown", AppliedAt: time.Now().UTC()}).Executor().Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rollback(db, 1, NoFTS5); err == nil {
+		t.Error("rolling back from an unknown version succeeded")
+	}
+
+	if err := Migrate(db, 1, NoFTS5); err == nil {
+		t.Error("migrating from an unknown version succeeded")
+	}
+
+	if statement := tableSQL(t, db, "Wishlist"); len(statement) <= 0 {
+		t.Error("the wishlists were removed by a failed rollback")
+	}
+}
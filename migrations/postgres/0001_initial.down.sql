@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "WishlistViewer";
+DROP TABLE IF EXISTS "Item";
+DROP TABLE IF EXISTS "Wishlist";
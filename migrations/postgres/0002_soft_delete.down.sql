@@ -0,0 +1,2 @@
+ALTER TABLE "Item" DROP COLUMN "DeletedAt";
+ALTER TABLE "Wishlist" DROP COLUMN "DeletedAt";
@@ -0,0 +1,2 @@
+ALTER TABLE "Wishlist" ADD COLUMN "DeletedAt" TIMESTAMP;
+ALTER TABLE "Item" ADD COLUMN "DeletedAt" TIMESTAMP;
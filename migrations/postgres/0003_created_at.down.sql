@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "Item_WishlistId";
+
+ALTER TABLE "Item" DROP COLUMN "CreatedAt";
+ALTER TABLE "Wishlist" DROP COLUMN "CreatedAt";
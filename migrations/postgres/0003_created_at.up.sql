@@ -0,0 +1,4 @@
+ALTER TABLE "Wishlist" ADD COLUMN "CreatedAt" TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+ALTER TABLE "Item" ADD COLUMN "CreatedAt" TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+
+CREATE INDEX IF NOT EXISTS "Item_WishlistId" ON "Item" ("WishlistId");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "AuditLog_WishlistId";
+DROP TABLE IF EXISTS "AuditLog";
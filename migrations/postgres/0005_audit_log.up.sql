@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "AuditLog" (
+    "Id" TEXT PRIMARY KEY,
+    "CreatedAt" TIMESTAMP NOT NULL,
+    "Actor" TEXT NOT NULL,
+    "WishlistId" TEXT NOT NULL,
+    "Entity" TEXT NOT NULL,
+    "EntityId" TEXT NOT NULL,
+    "Action" TEXT NOT NULL,
+    "Before" TEXT NOT NULL DEFAULT '',
+    "After" TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS "AuditLog_WishlistId" ON "AuditLog" ("WishlistId", "CreatedAt");
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "AuditLog_Actor";
+
+ALTER TABLE "AuditLog" DROP COLUMN "Target";
+ALTER TABLE "AuditLog" DROP COLUMN "State";
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS "Wishlist_Search" ON "Wishlist";
+DROP TRIGGER IF EXISTS "Item_Search" ON "Item";
+DROP FUNCTION IF EXISTS "Wishlist_Search"();
+DROP FUNCTION IF EXISTS "Item_Search"();
+
+DROP TABLE IF EXISTS "SearchIndex";
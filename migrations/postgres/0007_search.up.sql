@@ -0,0 +1,65 @@
+CREATE TABLE IF NOT EXISTS "SearchIndex" (
+	"Entity"      TEXT NOT NULL,
+	"EntityId"    TEXT NOT NULL,
+	"Name"        TEXT NOT NULL DEFAULT '',
+	"Description" TEXT NOT NULL DEFAULT '',
+	"Host"        TEXT NOT NULL DEFAULT '',
+	"Document"    tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', "Name"), 'A') ||
+		setweight(to_tsvector('simple', "Description"), 'B') ||
+		setweight(to_tsvector('simple', "Host"), 'C')
+	) STORED,
+	PRIMARY KEY("Entity", "EntityId")
+);
+
+CREATE INDEX IF NOT EXISTS "SearchIndex_Document" ON "SearchIndex" USING GIN ("Document");
+
+-- The host of an item is the part of its URL between the scheme and the path.
+CREATE OR REPLACE FUNCTION "Item_Search"() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		DELETE FROM "SearchIndex" WHERE "Entity" = 'Item' AND "EntityId" = OLD."Id";
+		RETURN OLD;
+	END IF;
+
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host") VALUES (
+		'Item',
+		NEW."Id",
+		COALESCE(NEW."Name", ''),
+		COALESCE(NEW."Description", ''),
+		COALESCE(substring(NEW."Url" from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?([^/?#]+)'), '')
+	) ON CONFLICT ("Entity", "EntityId") DO UPDATE SET
+		"Name" = EXCLUDED."Name",
+		"Description" = EXCLUDED."Description",
+		"Host" = EXCLUDED."Host";
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION "Wishlist_Search"() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		DELETE FROM "SearchIndex" WHERE "Entity" = 'Wishlist' AND "EntityId" = OLD."Id";
+		RETURN OLD;
+	END IF;
+
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name")
+	VALUES ('Wishlist', NEW."Id", COALESCE(NEW."Name", ''))
+	ON CONFLICT ("Entity", "EntityId") DO UPDATE SET "Name" = EXCLUDED."Name";
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "Item_Search" AFTER INSERT OR UPDATE OF "Name", "Description", "Url" OR DELETE ON "Item"
+FOR EACH ROW EXECUTE FUNCTION "Item_Search"();
+
+CREATE TRIGGER "Wishlist_Search" AFTER INSERT OR UPDATE OF "Name" OR DELETE ON "Wishlist"
+FOR EACH ROW EXECUTE FUNCTION "Wishlist_Search"();
+
+INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name")
+SELECT 'Wishlist', "Id", COALESCE("Name", '') FROM "Wishlist";
+
+INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host")
+SELECT 'Item', "Id", COALESCE("Name", ''), COALESCE("Description", ''),
+	COALESCE(substring("Url" from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?([^/?#]+)'), '')
+FROM "Item";
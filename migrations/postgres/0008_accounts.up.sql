@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "User" (
+	"Id"           TEXT PRIMARY KEY,
+	"CreatedAt"    TIMESTAMP NOT NULL,
+	"Email"        TEXT NOT NULL UNIQUE,
+	"Name"         TEXT NOT NULL DEFAULT '',
+	"PasswordHash" TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "Session" (
+	"Id"        TEXT PRIMARY KEY,
+	"UserId"    TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL,
+	"ExpiresAt" TIMESTAMP NOT NULL,
+	FOREIGN KEY("UserId") REFERENCES "User"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "Session_UserId" ON "Session" ("UserId");
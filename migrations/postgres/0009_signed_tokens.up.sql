@@ -0,0 +1,39 @@
+-- Sessions are identified by the hash of their refresh token, and belong to a user or to an
+-- anonymous caller. Existing sessions can not be converted, so everyone has to log in again.
+DROP INDEX IF EXISTS "Session_UserId";
+DROP TABLE IF EXISTS "Session";
+
+CREATE TABLE IF NOT EXISTS "Session" (
+	"Id"        TEXT PRIMARY KEY,
+	"Subject"   TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL,
+	"ExpiresAt" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "Session_Subject" ON "Session" ("Subject");
+
+-- The raw session keys that were stored as owners, and the opaque identities that replace them.
+-- A key can be exchanged once for a session of its identity.
+CREATE TABLE IF NOT EXISTS "LegacyKey" (
+	"Key"      TEXT PRIMARY KEY,
+	"Identity" TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO "LegacyKey" ("Key", "Identity")
+SELECT "Key", md5(random()::text || clock_timestamp()::text) FROM (
+	SELECT "Ownership" AS "Key" FROM "Wishlist"
+	UNION SELECT "Ownership" FROM "WishlistViewer"
+	UNION SELECT "Actor" FROM "AuditLog"
+) AS "Keys" WHERE "Key" IS NOT NULL AND "Key" <> '' AND "Key" NOT LIKE 'user:%';
+
+UPDATE "Wishlist" SET "Ownership" = (SELECT "Identity" FROM "LegacyKey" WHERE "Key" = "Wishlist"."Ownership")
+WHERE "Ownership" IN (SELECT "Key" FROM "LegacyKey");
+UPDATE "WishlistViewer" SET "Ownership" = (SELECT "Identity" FROM "LegacyKey" WHERE "Key" = "WishlistViewer"."Ownership")
+WHERE "Ownership" IN (SELECT "Key" FROM "LegacyKey");
+UPDATE "AuditLog" SET "Actor" = (SELECT "Identity" FROM "LegacyKey" WHERE "Key" = "AuditLog"."Actor")
+WHERE "Actor" IN (SELECT "Key" FROM "LegacyKey");
+
+-- Users are identified by their ID, without a prefix.
+UPDATE "Wishlist" SET "Ownership" = substr("Ownership", 6) WHERE "Ownership" LIKE 'user:%';
+UPDATE "WishlistViewer" SET "Ownership" = substr("Ownership", 6) WHERE "Ownership" LIKE 'user:%';
+UPDATE "AuditLog" SET "Actor" = substr("Actor", 6) WHERE "Actor" LIKE 'user:%';
@@ -0,0 +1,4 @@
+-- The passwords can not be recovered from their hashes, so every wishlist gets a new password.
+ALTER TABLE "Wishlist" ADD COLUMN IF NOT EXISTS "Password" TEXT DEFAULT md5(random()::text || clock_timestamp()::text);
+
+ALTER TABLE "Wishlist" DROP COLUMN IF EXISTS "PasswordHash";
@@ -0,0 +1,6 @@
+-- Only a hash of the password of a wishlist is stored.
+ALTER TABLE "Wishlist" ADD COLUMN IF NOT EXISTS "PasswordHash" TEXT;
+
+UPDATE "Wishlist" SET "PasswordHash" = encode(sha256(convert_to("Password", 'UTF8')), 'hex') WHERE "Password" IS NOT NULL;
+
+ALTER TABLE "Wishlist" DROP COLUMN IF EXISTS "Password";
@@ -0,0 +1 @@
+ALTER TABLE "WishlistViewer" DROP COLUMN "CreatedAt";
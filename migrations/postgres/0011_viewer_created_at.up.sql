@@ -0,0 +1 @@
+ALTER TABLE "WishlistViewer" ADD COLUMN "CreatedAt" TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "Invitation_WishlistId";
+DROP TABLE IF EXISTS "Invitation";
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "Invitation" (
+	"Id"             TEXT PRIMARY KEY,
+	"WishlistId"     TEXT NOT NULL,
+	"TokenHash"      TEXT NOT NULL UNIQUE,
+	"Permissions"    TEXT NOT NULL DEFAULT 'VIEW' CHECK("Permissions" = 'VIEW' OR "Permissions" = 'EDIT'),
+	"CreatedAt"      TIMESTAMP NOT NULL,
+	"ExpiresAt"      TIMESTAMP NOT NULL,
+	"MaxRedemptions" INTEGER NOT NULL DEFAULT 0,
+	"Redemptions"    INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY("WishlistId") REFERENCES "Wishlist"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "Invitation_WishlistId" ON "Invitation" ("WishlistId");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "ApiKey_UserId";
+DROP TABLE IF EXISTS "ApiKey";
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "ApiKey" (
+	"Id"         TEXT PRIMARY KEY,
+	"UserId"     TEXT NOT NULL,
+	"Name"       TEXT NOT NULL,
+	"KeyHash"    TEXT NOT NULL UNIQUE,
+	"Scopes"     TEXT NOT NULL,
+	"CreatedAt"  TIMESTAMP NOT NULL,
+	"LastUsedAt" TIMESTAMP,
+	FOREIGN KEY("UserId") REFERENCES "User"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "ApiKey_UserId" ON "ApiKey" ("UserId");
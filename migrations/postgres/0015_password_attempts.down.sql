@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "PasswordThrottle";
+DROP INDEX IF EXISTS "FailedPasswordAttempt_WishlistId";
+DROP TABLE IF EXISTS "FailedPasswordAttempt";
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "Pledge_Contributor";
+DROP TABLE IF EXISTS "Pledge";
+ALTER TABLE "Item" DROP COLUMN "Currency";
+ALTER TABLE "Item" DROP COLUMN "Price";
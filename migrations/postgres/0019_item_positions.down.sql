@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "Item_WishlistId_Position";
+ALTER TABLE "Item" DROP COLUMN "Position";
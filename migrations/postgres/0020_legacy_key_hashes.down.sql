@@ -0,0 +1,7 @@
+-- The keys can not be recovered from their hashes, so the keys that were not exchanged yet are
+-- forgotten. Their wishlists keep the identities they were moved to.
+DELETE FROM "LegacyKey";
+
+ALTER TABLE "LegacyKey" DROP COLUMN IF EXISTS "KeyHash";
+
+ALTER TABLE "LegacyKey" ADD COLUMN IF NOT EXISTS "Key" TEXT PRIMARY KEY;
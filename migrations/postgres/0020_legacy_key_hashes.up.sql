@@ -0,0 +1,9 @@
+-- Only the hashes of the session keys from before accounts existed are kept, like those of other
+-- secrets.
+ALTER TABLE "LegacyKey" ADD COLUMN IF NOT EXISTS "KeyHash" TEXT;
+
+UPDATE "LegacyKey" SET "KeyHash" = encode(sha256(convert_to("Key", 'UTF8')), 'hex');
+
+ALTER TABLE "LegacyKey" DROP COLUMN IF EXISTS "Key";
+
+ALTER TABLE "LegacyKey" ADD PRIMARY KEY ("KeyHash");
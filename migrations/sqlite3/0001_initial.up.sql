@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "Wishlist" (
+	"Id"        TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+	"Password"  TEXT DEFAULT (lower(hex(randomblob(16)))),
+	"Name"      TEXT NOT NULL DEFAULT 'Wishlist',
+	"Ownership" TEXT
+);
+
+CREATE TABLE IF NOT EXISTS "Item" (
+	"Id"          TEXT DEFAULT (lower(hex(randomblob(16)))),
+	"Url"         TEXT,
+	"WishlistId"  TEXT NOT NULL,
+	"Name"        TEXT DEFAULT (''),
+	"Description" TEXT DEFAULT (''),
+	"Image"       TEXT DEFAULT (''),
+	PRIMARY KEY("Id"),
+	CONSTRAINT "fk_wishlist_item" FOREIGN KEY("WishlistId") REFERENCES "Wishlist"("Id")
+);
+
+CREATE TABLE IF NOT EXISTS "WishlistViewer" (
+	"WishlistId"  TEXT,
+	"Ownership"   TEXT,
+	"Permissions" TEXT NOT NULL DEFAULT 'VIEW' CHECK("Permissions" = 'VIEW' OR "Permissions" = 'EDIT'),
+	PRIMARY KEY("WishlistId", "Ownership"),
+	FOREIGN KEY("WishlistId") REFERENCES "Wishlist"("Id")
+);
@@ -0,0 +1,9 @@
+-- SQLite cannot add columns with a non-constant default, so existing rows are filled in
+-- afterwards. New rows get their creation date from the repositories.
+ALTER TABLE "Wishlist" ADD COLUMN "CreatedAt" TIMESTAMP;
+ALTER TABLE "Item" ADD COLUMN "CreatedAt" TIMESTAMP;
+
+UPDATE "Wishlist" SET "CreatedAt" = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now');
+UPDATE "Item" SET "CreatedAt" = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now');
+
+CREATE INDEX IF NOT EXISTS "Item_WishlistId" ON "Item" ("WishlistId");
@@ -0,0 +1,2 @@
+ALTER TABLE "Item" DROP COLUMN "Version";
+ALTER TABLE "Wishlist" DROP COLUMN "Version";
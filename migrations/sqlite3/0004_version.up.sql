@@ -0,0 +1,2 @@
+ALTER TABLE "Wishlist" ADD COLUMN "Version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "Item" ADD COLUMN "Version" INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+ALTER TABLE "AuditLog" ADD COLUMN "State" TEXT NOT NULL DEFAULT 'APPLIED';
+ALTER TABLE "AuditLog" ADD COLUMN "Target" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS "AuditLog_Actor" ON "AuditLog" ("WishlistId", "Actor", "State");
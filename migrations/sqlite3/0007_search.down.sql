@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS "Wishlist_SearchDelete";
+DROP TRIGGER IF EXISTS "Wishlist_SearchUpdate";
+DROP TRIGGER IF EXISTS "Wishlist_SearchInsert";
+DROP TRIGGER IF EXISTS "Item_SearchDelete";
+DROP TRIGGER IF EXISTS "Item_SearchUpdate";
+DROP TRIGGER IF EXISTS "Item_SearchInsert";
+
+DROP TABLE IF EXISTS "SearchIndex";
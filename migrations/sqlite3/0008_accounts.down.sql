@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "Session_UserId";
+DROP TABLE IF EXISTS "Session";
+DROP TABLE IF EXISTS "User";
@@ -0,0 +1,26 @@
+UPDATE "Wishlist" SET "Ownership" = 'user:' || "Ownership" WHERE "Ownership" IN (SELECT "Id" FROM "User");
+UPDATE "WishlistViewer" SET "Ownership" = 'user:' || "Ownership" WHERE "Ownership" IN (SELECT "Id" FROM "User");
+UPDATE "AuditLog" SET "Actor" = 'user:' || "Actor" WHERE "Actor" IN (SELECT "Id" FROM "User");
+
+-- Keys that were already exchanged are forgotten, their wishlists keep the identity they were moved to.
+UPDATE "Wishlist" SET "Ownership" = (SELECT "Key" FROM "LegacyKey" WHERE "Identity" = "Wishlist"."Ownership")
+WHERE "Ownership" IN (SELECT "Identity" FROM "LegacyKey");
+UPDATE "WishlistViewer" SET "Ownership" = (SELECT "Key" FROM "LegacyKey" WHERE "Identity" = "WishlistViewer"."Ownership")
+WHERE "Ownership" IN (SELECT "Identity" FROM "LegacyKey");
+UPDATE "AuditLog" SET "Actor" = (SELECT "Key" FROM "LegacyKey" WHERE "Identity" = "AuditLog"."Actor")
+WHERE "Actor" IN (SELECT "Identity" FROM "LegacyKey");
+
+DROP TABLE IF EXISTS "LegacyKey";
+
+DROP INDEX IF EXISTS "Session_Subject";
+DROP TABLE IF EXISTS "Session";
+
+CREATE TABLE IF NOT EXISTS "Session" (
+	"Id"        TEXT PRIMARY KEY,
+	"UserId"    TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL,
+	"ExpiresAt" TIMESTAMP NOT NULL,
+	FOREIGN KEY("UserId") REFERENCES "User"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "Session_UserId" ON "Session" ("UserId");
@@ -0,0 +1,11 @@
+-- The passwords can not be recovered from their hashes, so every wishlist gets a new password.
+ALTER TABLE "Wishlist" ADD COLUMN "Password" TEXT;
+
+UPDATE "Wishlist" SET "Password" = lower(hex(randomblob(16)));
+
+-- Columns that are added later can not have a random default, so new passwords are generated by a trigger.
+CREATE TRIGGER IF NOT EXISTS "Wishlist_Password" AFTER INSERT ON "Wishlist" WHEN NEW."Password" IS NULL BEGIN
+	UPDATE "Wishlist" SET "Password" = lower(hex(randomblob(16))) WHERE "Id" = NEW."Id";
+END;
+
+ALTER TABLE "Wishlist" DROP COLUMN "PasswordHash";
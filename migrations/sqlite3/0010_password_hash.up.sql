@@ -0,0 +1,10 @@
+-- Only a hash of the password of a wishlist is stored. The sha256_hex function is registered
+-- by the application on every connection.
+ALTER TABLE "Wishlist" ADD COLUMN "PasswordHash" TEXT;
+
+-- Generates the passwords of new wishlists after this migration was rolled back.
+DROP TRIGGER IF EXISTS "Wishlist_Password";
+
+UPDATE "Wishlist" SET "PasswordHash" = sha256_hex("Password") WHERE "Password" IS NOT NULL;
+
+ALTER TABLE "Wishlist" DROP COLUMN "Password";
@@ -0,0 +1,5 @@
+-- SQLite cannot add columns with a non-constant default, so existing rows are filled in
+-- afterwards. New rows get their creation date from the repositories.
+ALTER TABLE "WishlistViewer" ADD COLUMN "CreatedAt" TIMESTAMP;
+
+UPDATE "WishlistViewer" SET "CreatedAt" = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now');
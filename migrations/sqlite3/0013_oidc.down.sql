@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "ExternalIdentity_UserId";
+DROP TABLE IF EXISTS "ExternalIdentity";
+DROP TABLE IF EXISTS "LoginAttempt";
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "LoginAttempt" (
+	"Id"        TEXT PRIMARY KEY,
+	"Verifier"  TEXT NOT NULL,
+	"Nonce"     TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL,
+	"ExpiresAt" TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "ExternalIdentity" (
+	"Issuer"    TEXT NOT NULL,
+	"Subject"   TEXT NOT NULL,
+	"UserId"    TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL,
+	PRIMARY KEY("Issuer", "Subject"),
+	FOREIGN KEY("UserId") REFERENCES "User"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "ExternalIdentity_UserId" ON "ExternalIdentity" ("UserId");
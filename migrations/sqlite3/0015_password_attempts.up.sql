@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "FailedPasswordAttempt" (
+	"Id"         TEXT PRIMARY KEY,
+	"WishlistId" TEXT NOT NULL,
+	"Ownership"  TEXT NOT NULL,
+	"ClientIp"   TEXT NOT NULL,
+	"CreatedAt"  TIMESTAMP NOT NULL,
+	FOREIGN KEY("WishlistId") REFERENCES "Wishlist"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "FailedPasswordAttempt_WishlistId" ON "FailedPasswordAttempt" ("WishlistId", "CreatedAt");
+
+CREATE TABLE IF NOT EXISTS "PasswordThrottle" (
+	"Key"         TEXT PRIMARY KEY,
+	"Failures"    INTEGER NOT NULL DEFAULT 0,
+	"LockedUntil" TIMESTAMP NOT NULL,
+	"UpdatedAt"   TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "Reservation_Reserver";
+DROP TABLE IF EXISTS "Reservation";
+ALTER TABLE "Item" DROP COLUMN "Quantity";
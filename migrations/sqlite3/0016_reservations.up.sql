@@ -0,0 +1,13 @@
+ALTER TABLE "Item" ADD COLUMN "Quantity" INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS "Reservation" (
+	"ItemId"    TEXT NOT NULL,
+	"Reserver"  TEXT NOT NULL,
+	"Quantity"  INTEGER NOT NULL DEFAULT 1 CHECK("Quantity" > 0),
+	"Note"      TEXT NOT NULL DEFAULT '',
+	"CreatedAt" TIMESTAMP NOT NULL,
+	PRIMARY KEY("ItemId", "Reserver"),
+	FOREIGN KEY("ItemId") REFERENCES "Item"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "Reservation_Reserver" ON "Reservation" ("Reserver");
@@ -0,0 +1,15 @@
+ALTER TABLE "Item" ADD COLUMN "Price" INTEGER;
+ALTER TABLE "Item" ADD COLUMN "Currency" TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "Pledge" (
+	"ItemId"      TEXT NOT NULL,
+	"Contributor" TEXT NOT NULL,
+	"Amount"      INTEGER NOT NULL CHECK("Amount" > 0),
+	"Currency"    TEXT NOT NULL,
+	"Message"     TEXT NOT NULL DEFAULT '',
+	"CreatedAt"   TIMESTAMP NOT NULL,
+	PRIMARY KEY("ItemId", "Contributor"),
+	FOREIGN KEY("ItemId") REFERENCES "Item"("Id")
+);
+
+CREATE INDEX IF NOT EXISTS "Pledge_Contributor" ON "Pledge" ("Contributor");
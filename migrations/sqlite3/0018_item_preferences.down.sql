@@ -0,0 +1,3 @@
+ALTER TABLE "Item" DROP COLUMN "Received";
+ALTER TABLE "Item" DROP COLUMN "Notes";
+ALTER TABLE "Item" DROP COLUMN "Priority";
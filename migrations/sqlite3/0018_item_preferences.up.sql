@@ -0,0 +1,3 @@
+ALTER TABLE "Item" ADD COLUMN "Priority" TEXT NOT NULL DEFAULT 'nice-to-have';
+ALTER TABLE "Item" ADD COLUMN "Notes" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "Item" ADD COLUMN "Received" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+ALTER TABLE "Item" ADD COLUMN "Position" INTEGER NOT NULL DEFAULT 0;
+
+-- Existing items keep the order they were added in.
+UPDATE "Item" SET "Position" = 1024 * (
+	SELECT COUNT(*) FROM "Item" AS "Other"
+	WHERE "Other"."WishlistId" = "Item"."WishlistId"
+	AND ("Other"."CreatedAt" < "Item"."CreatedAt" OR ("Other"."CreatedAt" = "Item"."CreatedAt" AND "Other"."Id" <= "Item"."Id"))
+);
+
+CREATE INDEX IF NOT EXISTS "Item_WishlistId_Position" ON "Item" ("WishlistId", "Position");
@@ -0,0 +1,8 @@
+-- The keys can not be recovered from their hashes, so the keys that were not exchanged yet are
+-- forgotten. Their wishlists keep the identities they were moved to.
+DROP TABLE IF EXISTS "LegacyKey";
+
+CREATE TABLE IF NOT EXISTS "LegacyKey" (
+	"Key"      TEXT PRIMARY KEY,
+	"Identity" TEXT NOT NULL UNIQUE
+);
@@ -0,0 +1,13 @@
+-- Only the hashes of the session keys from before accounts existed are kept, like those of other
+-- secrets. The sha256_hex function is registered by the application on every connection.
+CREATE TABLE IF NOT EXISTS "LegacyKeyHash" (
+	"KeyHash"  TEXT PRIMARY KEY,
+	"Identity" TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO "LegacyKeyHash" ("KeyHash", "Identity")
+SELECT sha256_hex("Key"), "Identity" FROM "LegacyKey";
+
+DROP TABLE IF EXISTS "LegacyKey";
+
+ALTER TABLE "LegacyKeyHash" RENAME TO "LegacyKey";
@@ -0,0 +1,62 @@
+-- Used instead of the full-text index when SQLite is built without FTS5. Searches scan this
+-- table, the triggers keep it up to date all the same.
+CREATE TABLE IF NOT EXISTS "SearchIndex" (
+	"Entity"      TEXT NOT NULL,
+	"EntityId"    TEXT NOT NULL,
+	"Name"        TEXT NOT NULL,
+	"Description" TEXT NOT NULL,
+	"Host"        TEXT NOT NULL
+);
+
+-- The host of an item is the part of its URL between the scheme and the path.
+CREATE TRIGGER IF NOT EXISTS "Item_SearchInsert" AFTER INSERT ON "Item" BEGIN
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host") VALUES (
+		'Item',
+		NEW."Id",
+		COALESCE(NEW."Name", ''),
+		COALESCE(NEW."Description", ''),
+		(SELECT substr("Rest", 1, instr("Rest" || '/', '/') - 1) FROM (
+			SELECT substr(COALESCE(NEW."Url", ''), instr(COALESCE(NEW."Url", ''), '://') + 3 * (instr(COALESCE(NEW."Url", ''), '://') > 0)) AS "Rest"
+		))
+	);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "Item_SearchUpdate" AFTER UPDATE OF "Name", "Description", "Url" ON "Item" BEGIN
+	DELETE FROM "SearchIndex" WHERE "Entity" = 'Item' AND "EntityId" = OLD."Id";
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host") VALUES (
+		'Item',
+		NEW."Id",
+		COALESCE(NEW."Name", ''),
+		COALESCE(NEW."Description", ''),
+		(SELECT substr("Rest", 1, instr("Rest" || '/', '/') - 1) FROM (
+			SELECT substr(COALESCE(NEW."Url", ''), instr(COALESCE(NEW."Url", ''), '://') + 3 * (instr(COALESCE(NEW."Url", ''), '://') > 0)) AS "Rest"
+		))
+	);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "Item_SearchDelete" AFTER DELETE ON "Item" BEGIN
+	DELETE FROM "SearchIndex" WHERE "Entity" = 'Item' AND "EntityId" = OLD."Id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "Wishlist_SearchInsert" AFTER INSERT ON "Wishlist" BEGIN
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host")
+	VALUES ('Wishlist', NEW."Id", COALESCE(NEW."Name", ''), '', '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "Wishlist_SearchUpdate" AFTER UPDATE OF "Name" ON "Wishlist" BEGIN
+	DELETE FROM "SearchIndex" WHERE "Entity" = 'Wishlist' AND "EntityId" = OLD."Id";
+	INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host")
+	VALUES ('Wishlist', NEW."Id", COALESCE(NEW."Name", ''), '', '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "Wishlist_SearchDelete" AFTER DELETE ON "Wishlist" BEGIN
+	DELETE FROM "SearchIndex" WHERE "Entity" = 'Wishlist' AND "EntityId" = OLD."Id";
+END;
+
+INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host")
+SELECT 'Wishlist', "Id", COALESCE("Name", ''), '', '' FROM "Wishlist";
+
+INSERT INTO "SearchIndex" ("Entity", "EntityId", "Name", "Description", "Host")
+SELECT 'Item', "Id", COALESCE("Name", ''), COALESCE("Description", ''), substr("Rest", 1, instr("Rest" || '/', '/') - 1) FROM (
+	SELECT *, substr(COALESCE("Url", ''), instr(COALESCE("Url", ''), '://') + 3 * (instr(COALESCE("Url", ''), '://') > 0)) AS "Rest" FROM "Item"
+);
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"os"
+	"slices"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// How often the moment an API key was last used is updated, so not every request writes to the database.
+const apiKeyUsageInterval = time.Minute
+
+// A named key a user can authenticate scripts and integrations with, instead of a session. Only a
+// hash of the key is stored.
+type ApiKey struct {
+	Id     string `json:"id" db:"Id"`
+	UserId string `json:"-" db:"UserId"`
+	Name   string `json:"name" db:"Name"`
+	// The key itself. It is never stored, so it is only set right after it was generated.
+	Key     string `json:"key,omitempty" db:"-"`
+	KeyHash string `json:"-" db:"KeyHash"`
+	// The space-separated scopes that limit what the key can be used for.
+	Scopes     string     `json:"scopes" db:"Scopes"`
+	CreatedAt  time.Time  `json:"createdAt" db:"CreatedAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt" db:"LastUsedAt"`
+}
+
+// Stores the API key, generating its ID. The hash of the key has to be set by the caller.
+func (repo *UserRepository) AddApiKey(key ApiKey) (*ApiKey, error) {
+	key.Id = NewId()
+	key.CreatedAt = time.Now().UTC()
+	key.LastUsedAt = nil
+
+	if _, err := repo.db.Insert("ApiKey").Rows(key).Executor().Exec(); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// Returns the API keys of the user, newest first.
+func (repo *UserRepository) GetApiKeys(userId string) ([]ApiKey, error) {
+	keys := []ApiKey{}
+	err := repo.db.From("ApiKey").
+		Where(goqu.C("UserId").Eq(userId)).
+		Order(goqu.C("CreatedAt").Desc()).
+		ScanStructs(&keys)
+
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revokes the API key, so it can no longer be used. Returns os.ErrNotExist if the user has no key
+// with the given ID.
+func (repo *UserRepository) DeleteApiKey(userId string, id string) error {
+	result, err := repo.db.Delete("ApiKey").
+		Where(goqu.C("UserId").Eq(userId), goqu.C("Id").Eq(id)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Returns the API key with the given hash, and records that it was used at the given moment.
+// Returns os.ErrNotExist for unknown or revoked keys.
+func (repo *UserRepository) UseApiKey(keyHash string, at time.Time) (*ApiKey, error) {
+	var key ApiKey
+	found, err := repo.db.From("ApiKey").Where(goqu.C("KeyHash").Eq(keyHash)).ScanStruct(&key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
+	at = at.UTC()
+	if key.LastUsedAt == nil || key.LastUsedAt.Add(apiKeyUsageInterval).Before(at) {
+		_, err := repo.db.Update("ApiKey").
+			Set(goqu.Record{"LastUsedAt": at}).
+			Where(goqu.C("Id").Eq(key.Id)).
+			Executor().Exec()
+
+		if err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &at
+	}
+
+	return &key, nil
+}
+
+// Stores the API key, generating its ID. The hash of the key has to be set by the caller.
+func (repo *MemoryUserRepository) AddApiKey(key ApiKey) (*ApiKey, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key.Id = NewId()
+	key.CreatedAt = time.Now().UTC()
+	key.LastUsedAt = nil
+	key.Key = ""
+	repo.apiKeys = append(repo.apiKeys, key)
+	return &key, nil
+}
+
+// Returns the API keys of the user, newest first.
+func (repo *MemoryUserRepository) GetApiKeys(userId string) ([]ApiKey, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	keys := []ApiKey{}
+	for _, key := range slices.Backward(repo.apiKeys) {
+		if key.UserId == userId {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Revokes the API key, so it can no longer be used. Returns os.ErrNotExist if the user has no key
+// with the given ID.
+func (repo *MemoryUserRepository) DeleteApiKey(userId string, id string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	count := len(repo.apiKeys)
+	repo.apiKeys = slices.DeleteFunc(repo.apiKeys, func(key ApiKey) bool {
+		return key.UserId == userId && key.Id == id
+	})
+
+	if len(repo.apiKeys) == count {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Returns the API key with the given hash, and records that it was used at the given moment.
+// Returns os.ErrNotExist for unknown or revoked keys.
+func (repo *MemoryUserRepository) UseApiKey(keyHash string, at time.Time) (*ApiKey, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	index := slices.IndexFunc(repo.apiKeys, func(key ApiKey) bool {
+		return key.KeyHash == keyHash
+	})
+
+	if index < 0 {
+		return nil, os.ErrNotExist
+	}
+
+	at = at.UTC()
+	repo.apiKeys[index].LastUsedAt = &at
+
+	key := repo.apiKeys[index]
+	return &key, nil
+}
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// How the guessing of wishlist passwords is slowed down. Both a wishlist and a client can fail a
+// few times for free, after which every failure locks them out twice as long as the one before.
+const (
+	// The failures a client may make across all wishlists before it is locked out.
+	clientFreeAttempts = 5
+	// The failures that may be made on a single wishlist, by any client, before it is locked. This
+	// is higher than the limit of a client, so a single client can not easily lock out everyone else.
+	wishlistFreeAttempts = 20
+	// The lockout after the first failure that is not free.
+	baseLockout = time.Minute
+	maxLockout  = 24 * time.Hour
+	// The amount of times the base lockout is doubled before it exceeds the maximum lockout.
+	maxDoublings = 11
+	// Failures are forgotten when there were no new failures for this long.
+	failureWindow = 24 * time.Hour
+	// The amount of failed attempts that is kept in the log of a wishlist.
+	maxLoggedAttempts = 100
+)
+
+// A wrong password that was used to get edit permissions for a wishlist.
+type FailedPasswordAttempt struct {
+	Id         string `db:"Id"`
+	WishlistId string `db:"WishlistId"`
+	// The identity of the caller that made the attempt.
+	Ownership string    `db:"Ownership"`
+	ClientIp  string    `db:"ClientIp"`
+	CreatedAt time.Time `db:"CreatedAt"`
+}
+
+// The failures of a wishlist or client, and until when it is locked out because of them.
+type passwordThrottle struct {
+	Key         string    `db:"Key"`
+	Failures    int       `db:"Failures"`
+	LockedUntil time.Time `db:"LockedUntil"`
+	UpdatedAt   time.Time `db:"UpdatedAt"`
+}
+
+// Counts another failure, and locks the throttle out if it used up its free attempts.
+func (throttle *passwordThrottle) fail(free int, at time.Time) {
+	if at.Sub(throttle.UpdatedAt) > failureWindow {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.UpdatedAt = at
+
+	if throttle.Failures <= free {
+		return
+	}
+
+	// The exponent is clamped, so shifting the lockout can not overflow.
+	exponent := min(throttle.Failures-free-1, maxDoublings)
+	throttle.LockedUntil = at.Add(min(baseLockout<<exponent, maxLockout))
+}
+
+// The keys of the throttles of a wishlist and a client, with the failures they may make for free.
+func throttleKeys(wishlistId string, clientIp string) map[string]int {
+	return map[string]int{
+		"wishlist:" + wishlistId: wishlistFreeAttempts,
+		"client:" + clientIp:     clientFreeAttempts,
+	}
+}
+
+type PasswordAttemptRepository struct {
+	db Database
+}
+
+func NewPasswordAttemptRepository(db Database) *PasswordAttemptRepository {
+	return &PasswordAttemptRepository{db: db}
+}
+
+// Returns the moment the wishlist or the client can try a password again. This is in the past, or
+// the zero time, if neither is locked out.
+func (repo *PasswordAttemptRepository) GetLockout(wishlistId string, clientIp string) (time.Time, error) {
+	keys := []string{}
+	for key := range throttleKeys(wishlistId, clientIp) {
+		keys = append(keys, key)
+	}
+
+	throttles := []passwordThrottle{}
+	if err := repo.db.From("PasswordThrottle").Where(goqu.C("Key").In(keys)).ScanStructs(&throttles); err != nil {
+		return time.Time{}, err
+	}
+
+	var until time.Time
+	for _, throttle := range throttles {
+		if throttle.LockedUntil.After(until) {
+			until = throttle.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// Locks the throttles of the wishlist and the client until the transaction ends, and returns the
+// moment either can try a password again. Checking a password and counting its failure in the same
+// transaction makes concurrent attempts wait for each other, so they can not all pass the check
+// before the first failure is counted.
+func (repo *PasswordAttemptRepository) LockThrottles(wishlistId string, clientIp string) (time.Time, error) {
+	var until time.Time
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		keys := []string{}
+		for key := range throttleKeys(wishlistId, clientIp) {
+			// The throttles need to exist to be locked.
+			_, err := tx.Insert("PasswordThrottle").
+				Rows(passwordThrottle{Key: key}).
+				OnConflict(goqu.DoNothing()).
+				Executor().Exec()
+
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		_, err := tx.Update("PasswordThrottle").
+			Set(goqu.Record{"Key": goqu.C("Key")}).
+			Where(goqu.C("Key").In(keys)).
+			Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		until, err = NewPasswordAttemptRepository(tx).GetLockout(wishlistId, clientIp)
+		return err
+	})
+
+	return until, err
+}
+
+// Logs the failed attempt and counts it against both the wishlist and the client. Returns the
+// moment either can try a password again.
+func (repo *PasswordAttemptRepository) AddFailedAttempt(attempt FailedPasswordAttempt) (time.Time, error) {
+	attempt.Id = NewId()
+	attempt.CreatedAt = attempt.CreatedAt.UTC()
+
+	var until time.Time
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		if _, err := tx.Insert("FailedPasswordAttempt").Rows(attempt).Executor().Exec(); err != nil {
+			return err
+		}
+
+		for key, free := range throttleKeys(attempt.WishlistId, attempt.ClientIp) {
+			throttle := passwordThrottle{Key: key}
+			found, err := tx.From("PasswordThrottle").Where(goqu.C("Key").Eq(key)).ScanStruct(&throttle)
+			if err != nil {
+				return err
+			}
+
+			throttle.fail(free, attempt.CreatedAt)
+			if found {
+				_, err = tx.Update("PasswordThrottle").Set(throttle).Where(goqu.C("Key").Eq(key)).Executor().Exec()
+			} else {
+				_, err = tx.Insert("PasswordThrottle").Rows(throttle).Executor().Exec()
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if throttle.LockedUntil.After(until) {
+				until = throttle.LockedUntil
+			}
+		}
+		return nil
+	})
+
+	return until, err
+}
+
+// Forgets the failures of the wishlist, after its correct password was used. The failures of the
+// client are left to run out, otherwise the password of one wishlist could be used to keep guessing
+// those of others.
+func (repo *PasswordAttemptRepository) ResetFailures(wishlistId string) error {
+	_, err := repo.db.Delete("PasswordThrottle").Where(goqu.C("Key").Eq("wishlist:" + wishlistId)).Executor().Exec()
+	return err
+}
+
+// Returns the latest failed attempts on the wishlist, newest first.
+func (repo *PasswordAttemptRepository) GetFailedAttempts(wishlistId string) ([]FailedPasswordAttempt, error) {
+	attempts := []FailedPasswordAttempt{}
+	err := repo.db.From("FailedPasswordAttempt").
+		Where(goqu.C("WishlistId").Eq(wishlistId)).
+		Order(goqu.C("CreatedAt").Desc()).
+		Limit(maxLoggedAttempts).
+		ScanStructs(&attempts)
+
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// Permanently removes the failed attempts that were made before the given moment, and the
+// throttles that have not been locked out or failed since.
+func (repo *PasswordAttemptRepository) Purge(before time.Time) (int64, error) {
+	var count int64
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		_, err := tx.Delete("PasswordThrottle").Where(
+			goqu.C("UpdatedAt").Lt(before.UTC()),
+			goqu.C("LockedUntil").Lt(before.UTC()),
+		).Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Delete("FailedPasswordAttempt").
+			Where(goqu.C("CreatedAt").Lt(before.UTC())).
+			Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		count, err = result.RowsAffected()
+		return err
+	})
+
+	return count, err
+}
+
+// Keeps failed password attempts in memory.
+type MemoryPasswordAttemptRepository struct {
+	mutex     *sync.RWMutex
+	attempts  []FailedPasswordAttempt
+	throttles map[string]passwordThrottle
+	wishlists *MemoryWishlistRepository
+}
+
+func NewMemoryPasswordAttemptRepository(wishlists *MemoryWishlistRepository) *MemoryPasswordAttemptRepository {
+	return &MemoryPasswordAttemptRepository{
+		mutex:     &sync.RWMutex{},
+		attempts:  []FailedPasswordAttempt{},
+		throttles: map[string]passwordThrottle{},
+		wishlists: wishlists,
+	}
+}
+
+// Returns the moment the wishlist or the client can try a password again. This is in the past, or
+// the zero time, if neither is locked out.
+func (repo *MemoryPasswordAttemptRepository) GetLockout(wishlistId string, clientIp string) (time.Time, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var until time.Time
+	for key := range throttleKeys(wishlistId, clientIp) {
+		if throttle := repo.throttles[key]; throttle.LockedUntil.After(until) {
+			until = throttle.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// Returns the moment the wishlist or the client can try a password again. Transactions on memory
+// repositories already run one at a time, so the throttles do not need to be locked.
+func (repo *MemoryPasswordAttemptRepository) LockThrottles(wishlistId string, clientIp string) (time.Time, error) {
+	return repo.GetLockout(wishlistId, clientIp)
+}
+
+// Logs the failed attempt and counts it against both the wishlist and the client. Returns the
+// moment either can try a password again.
+func (repo *MemoryPasswordAttemptRepository) AddFailedAttempt(attempt FailedPasswordAttempt) (time.Time, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	attempt.Id = NewId()
+	attempt.CreatedAt = attempt.CreatedAt.UTC()
+	repo.attempts = append(repo.attempts, attempt)
+
+	var until time.Time
+	for key, free := range throttleKeys(attempt.WishlistId, attempt.ClientIp) {
+		throttle := repo.throttles[key]
+		throttle.Key = key
+		throttle.fail(free, attempt.CreatedAt)
+		repo.throttles[key] = throttle
+
+		if throttle.LockedUntil.After(until) {
+			until = throttle.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// Forgets the failures of the wishlist, after its correct password was used. The failures of the
+// client are left to run out, otherwise the password of one wishlist could be used to keep guessing
+// those of others.
+func (repo *MemoryPasswordAttemptRepository) ResetFailures(wishlistId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	delete(repo.throttles, "wishlist:"+wishlistId)
+	return nil
+}
+
+// Returns the latest failed attempts on the wishlist, newest first.
+func (repo *MemoryPasswordAttemptRepository) GetFailedAttempts(wishlistId string) ([]FailedPasswordAttempt, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	attempts := []FailedPasswordAttempt{}
+	for _, attempt := range slices.Backward(repo.attempts) {
+		if attempt.WishlistId == wishlistId && len(attempts) < maxLoggedAttempts {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+// Permanently removes the failed attempts that were made before the given moment, those of
+// wishlists that were purged, and the throttles that have not been locked out or failed since.
+func (repo *MemoryPasswordAttemptRepository) Purge(before time.Time) (int64, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.wishlists.mutex.RLock()
+	defer repo.wishlists.mutex.RUnlock()
+
+	for key, throttle := range repo.throttles {
+		if throttle.UpdatedAt.Before(before) && throttle.LockedUntil.Before(before) {
+			delete(repo.throttles, key)
+		}
+	}
+
+	count := len(repo.attempts)
+	repo.attempts = slices.DeleteFunc(repo.attempts, func(attempt FailedPasswordAttempt) bool {
+		_, ok := repo.wishlists.models[attempt.WishlistId]
+		return !ok || attempt.CreatedAt.Before(before)
+	})
+
+	return int64(count - len(repo.attempts)), nil
+}
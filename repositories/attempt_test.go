@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Guesses the password of the wishlist in a transaction, like the API does, and returns whether the
+// guess was counted, or rejected because of a lockout.
+func guessPassword(t *testing.T, repos *Repositories, wishlistId string, clientIp string) bool {
+	t.Helper()
+
+	counted := false
+	err := repos.Transaction(func(repos *Repositories) error {
+		until, err := repos.PasswordAttempts.LockThrottles(wishlistId, clientIp)
+		if err != nil || until.After(time.Now()) {
+			return err
+		}
+
+		counted = true
+		_, err = repos.PasswordAttempts.AddFailedAttempt(FailedPasswordAttempt{
+			WishlistId: wishlistId,
+			Ownership:  "guesser",
+			ClientIp:   clientIp,
+			CreatedAt:  time.Now(),
+		})
+		return err
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+	return counted
+}
+
+func TestConcurrentPasswordGuesses(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+
+		var wait sync.WaitGroup
+		for range 2 * clientFreeAttempts {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				guessPassword(t, repos, wishlist.Id, "192.0.2.1")
+			}()
+		}
+		wait.Wait()
+
+		// Only the guesses before the lockout are counted, the first of them that is not free locks out the client.
+		attempts, err := repos.PasswordAttempts.GetFailedAttempts(wishlist.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(attempts) != clientFreeAttempts+1 {
+			t.Errorf("%d guesses were counted, want %d", len(attempts), clientFreeAttempts+1)
+		}
+	})
+}
+
+func TestResetFailures(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		target, _ := addWishlist(t, repos, "owner", "Birthday")
+		own, _ := addWishlist(t, repos, "guesser", "Decoy")
+
+		for range clientFreeAttempts {
+			guessPassword(t, repos, target.Id, "192.0.2.1")
+		}
+
+		// Unlocking a wishlist of its own does not give the client more free guesses.
+		if err := repos.PasswordAttempts.ResetFailures(own.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		if !guessPassword(t, repos, target.Id, "192.0.2.1") {
+			t.Fatal("a free guess was rejected")
+		}
+
+		if guessPassword(t, repos, target.Id, "192.0.2.1") {
+			t.Error("the client can still guess after using up its free guesses")
+		}
+
+		// Neither does unlocking the wishlist it is locked out of.
+		if err := repos.PasswordAttempts.ResetFailures(target.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		if guessPassword(t, repos, target.Id, "192.0.2.1") {
+			t.Error("the client can guess again after the wishlist was unlocked")
+		}
+
+		// The wishlist itself was not locked out, as its limit is higher than that of a client.
+		if !guessPassword(t, repos, target.Id, "198.51.100.1") {
+			t.Error("another client was locked out of the wishlist")
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	AuditAdd     = "ADD"
+	AuditUpdate  = "UPDATE"
+	AuditDelete  = "DELETE"
+	AuditRestore = "RESTORE"
+	// Changes made by undoing or redoing another change.
+	AuditUndo = "UNDO"
+	AuditRedo = "REDO"
+)
+
+// The states of a change. Changes that were undone can be redone, until their actor
+// makes another change to the same wishlist, which discards them.
+const (
+	AuditApplied   = "APPLIED"
+	AuditUndone    = "UNDONE"
+	AuditDiscarded = "DISCARDED"
+)
+
+// The actions of the changes that can be undone.
+var undoableActions = []string{AuditAdd, AuditUpdate, AuditDelete, AuditRestore}
+
+// A JSON document that is stored as text. An empty document is represented as null.
+type JSON string
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) <= 0 {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// Implemented by models whose changes should show up in the history of a wishlist.
+type Audited interface {
+	// Returns the ID of the wishlist the model belongs to.
+	AuditScope() string
+}
+
+// A single change to a model.
+type AuditEntry struct {
+	Id        string    `json:"id" db:"Id"`
+	CreatedAt time.Time `json:"createdAt" db:"CreatedAt"`
+	// The session key of whoever made the change.
+	Actor      string `json:"-" db:"Actor"`
+	WishlistId string `json:"wishlistId" db:"WishlistId"`
+	// The table of the changed model.
+	Entity   string `json:"entity" db:"Entity"`
+	EntityId string `json:"entityId" db:"EntityId"`
+	Action   string `json:"action" db:"Action"`
+	// The JSON representation of the model before and after the change. Before is empty
+	// when the model was added or restored, after is empty when the model was deleted.
+	Before JSON   `json:"before" db:"Before"`
+	After  JSON   `json:"after" db:"After"`
+	State  string `json:"state" db:"State"`
+	// The ID of the change that was undone or redone by this change.
+	Target string `json:"target,omitempty" db:"Target"`
+}
+
+// Creates the audit entry of a change to a model.
+func newAuditEntry[T interface{}](actor string, action string, entity string, before *T, after *T) (*AuditEntry, error) {
+	entry := &AuditEntry{
+		Id:        NewId(),
+		CreatedAt: time.Now().UTC(),
+		Actor:     actor,
+		Entity:    entity,
+		Action:    action,
+		State:     AuditApplied,
+	}
+
+	for _, snapshot := range []struct {
+		model  *T
+		target *JSON
+	}{{before, &entry.Before}, {after, &entry.After}} {
+		if snapshot.model == nil {
+			continue
+		}
+
+		data, err := json.Marshal(snapshot.model)
+		if err != nil {
+			return nil, err
+		}
+		*snapshot.target = JSON(data)
+
+		entry.EntityId = fmt.Sprint(getId[T, any](*snapshot.model))
+		if audited, ok := any(*snapshot.model).(Audited); ok {
+			entry.WishlistId = audited.AuditScope()
+		}
+	}
+
+	return entry, nil
+}
+
+// Inserts the audit entry of a change to a model, as part of the transaction that made the change.
+func recordChange[T interface{}](tx *goqu.TxDatabase, actor string, action string, entity string, before *T, after *T) error {
+	entry, err := newAuditEntry(actor, action, entity, before, after)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Insert("AuditLog").Rows(entry).Executor().Exec(); err != nil {
+		return err
+	}
+
+	// The changes the actor undid can no longer be redone on top of the new change.
+	_, err = tx.Update("AuditLog").
+		Set(goqu.Record{"State": AuditDiscarded}).
+		Where(
+			goqu.C("WishlistId").Eq(entry.WishlistId),
+			goqu.C("Actor").Eq(actor),
+			goqu.C("State").Eq(AuditUndone),
+		).
+		Executor().Exec()
+	return err
+}
+
+type AuditRepository struct {
+	db Database
+}
+
+func NewAuditRepository(db Database) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Returns a page of the changes made to the wishlist and its items.
+func (repo *AuditRepository) GetHistory(wishlistId string, query Query) (*Page[AuditEntry], error) {
+	return paginate[AuditEntry](repo.db.From("AuditLog").Where(goqu.C("WishlistId").Eq(wishlistId)), query)
+}
+
+// An audit log that keeps its entries in memory.
+type MemoryAuditRepository struct {
+	mutex   *sync.RWMutex
+	entries []AuditEntry
+	// The repositories of the audited models by their name, used to undo and redo changes.
+	entities map[string]memoryEntity
+	// Runs a function in a transaction of the repositories the audit log belongs to, so undoing
+	// several changes either reverts all of them or none of them.
+	transaction func(fn func(repos *Repositories) error) error
+}
+
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{
+		mutex:    &sync.RWMutex{},
+		entries:  []AuditEntry{},
+		entities: map[string]memoryEntity{},
+	}
+}
+
+// Returns a page of the changes made to the wishlist and its items.
+func (repo *MemoryAuditRepository) GetHistory(wishlistId string, query Query) (*Page[AuditEntry], error) {
+	repo.mutex.RLock()
+	entries := slices.DeleteFunc(slices.Clone(repo.entries), func(entry AuditEntry) bool {
+		return entry.WishlistId != wishlistId
+	})
+	repo.mutex.RUnlock()
+
+	return paginateSlice(entries, query)
+}
+
+// Stores the audit entry of a change to a model.
+func (repo *MemoryAuditRepository) record(entry *AuditEntry) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.entries = append(repo.entries, *entry)
+
+	// The changes the actor undid can no longer be redone on top of the new change.
+	for i, stored := range repo.entries {
+		if stored.WishlistId == entry.WishlistId && stored.Actor == entry.Actor && stored.State == AuditUndone {
+			repo.entries[i].State = AuditDiscarded
+		}
+	}
+}
+
+// Copies the stored entries, and returns a function that restores them to the copy.
+func (repo *MemoryAuditRepository) snapshot() (restore func()) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	entries := slices.Clone(repo.entries)
+
+	return func() {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.entries = entries
+	}
+}
+
+// Attributes the changes of one actor to another.
+func (repo *MemoryAuditRepository) reattribute(from string, to string) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i := range repo.entries {
+		if repo.entries[i].Actor == from {
+			repo.entries[i].Actor = to
+		}
+	}
+}
+
+// Removes the history of the given wishlists.
+func (repo *MemoryAuditRepository) remove(isRemoved func(wishlistId string) bool) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.entries = slices.DeleteFunc(repo.entries, func(entry AuditEntry) bool {
+		return isRemoved(entry.WishlistId)
+	})
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/doug-martin/goqu/v9/dialect/sqlite3"
+)
+
+// SQLite supports RETURNING clauses since version 3.35, but goqu's sqlite3 dialect does
+// not enable them. The dialect is registered again so inserts can return the created row
+// on SQLite the same way they do on PostgreSQL.
+//
+// SQLite stores timestamps as text, so they are written with a fixed width to make sure
+// comparing them as text gives the same result as comparing them as time.
+func init() {
+	options := sqlite3.DialectOptions()
+	options.SupportsReturn = true
+	options.TimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+	goqu.RegisterDialect("sqlite3", options)
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Returned when an invitation does not exist, has expired or was redeemed too often.
+var ErrInvalidInvitation = errors.New("the invitation is invalid or has expired")
+
+// A link the owner of a wishlist shares to give others access to it. Unlike the password of the
+// wishlist, invitations expire and can only be redeemed a limited amount of times.
+type Invitation struct {
+	Id         string `json:"id" db:"Id"`
+	WishlistId string `json:"wishlistId" db:"WishlistId"`
+	// The token that redeems the invitation. It is never stored, so it is only set right after
+	// it was generated.
+	Token       string    `json:"token,omitempty" db:"-"`
+	TokenHash   string    `json:"-" db:"TokenHash"`
+	Permissions string    `json:"permission" db:"Permissions"`
+	CreatedAt   time.Time `json:"createdAt" db:"CreatedAt"`
+	ExpiresAt   time.Time `json:"expiresAt" db:"ExpiresAt"`
+	// The amount of times the invitation can be redeemed, or 0 if there is no limit.
+	MaxRedemptions int `json:"maxRedemptions" db:"MaxRedemptions"`
+	Redemptions    int `json:"redemptions" db:"Redemptions"`
+}
+
+// Returns whether the invitation can still be redeemed at the given moment.
+func (invitation Invitation) outstanding(at time.Time) bool {
+	return invitation.ExpiresAt.After(at) &&
+		(invitation.MaxRedemptions <= 0 || invitation.Redemptions < invitation.MaxRedemptions)
+}
+
+type InvitationRepository struct {
+	db Database
+}
+
+func NewInvitationRepository(db Database) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Stores the invitation and generates its ID and token. Only a hash of the token is stored, so the
+// returned invitation is the only place the token can be read from.
+func (repo *InvitationRepository) AddInvitation(invitation Invitation) (*Invitation, error) {
+	invitation.Id = NewId()
+	invitation.Token = NewId()
+	invitation.TokenHash = HashPassword(invitation.Token)
+	invitation.CreatedAt = time.Now().UTC()
+	invitation.ExpiresAt = invitation.ExpiresAt.UTC()
+	invitation.Redemptions = 0
+
+	if _, err := repo.db.Insert("Invitation").Rows(invitation).Executor().Exec(); err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// Returns the invitations of the wishlist that can still be redeemed at the given moment, newest first.
+func (repo *InvitationRepository) GetInvitations(wishlistId string, at time.Time) ([]Invitation, error) {
+	invitations := []Invitation{}
+	err := repo.db.From("Invitation").Where(
+		goqu.C("WishlistId").Eq(wishlistId),
+		goqu.C("ExpiresAt").Gt(at.UTC()),
+		goqu.Or(goqu.C("MaxRedemptions").Lte(0), goqu.C("Redemptions").Lt(goqu.C("MaxRedemptions"))),
+	).Order(goqu.C("CreatedAt").Desc()).ScanStructs(&invitations)
+
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// Revokes the invitation, so it can no longer be redeemed. Returns os.ErrNotExist if the wishlist
+// has no invitation with the given ID.
+func (repo *InvitationRepository) DeleteInvitation(wishlistId string, id string) error {
+	result, err := repo.db.Delete("Invitation").
+		Where(goqu.C("WishlistId").Eq(wishlistId), goqu.C("Id").Eq(id)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Redeems the invitation of the wishlist with the given token, and gives the owner the permissions of
+// the invitation. Owners that already have those permissions do not use up the invitation. Returns
+// ErrInvalidInvitation if the invitation can not be redeemed at the given moment.
+func (repo *InvitationRepository) RedeemInvitation(wishlistId string, token string, ownership string, at time.Time) (*Invitation, error) {
+	var invitation Invitation
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		found, err := tx.From("Invitation").Where(
+			goqu.C("WishlistId").Eq(wishlistId),
+			goqu.C("TokenHash").Eq(HashPassword(token)),
+		).ScanStruct(&invitation)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return ErrInvalidInvitation
+		}
+
+		var current string
+		granted, err := tx.From("WishlistViewer").Select("Permissions").Where(
+			goqu.C("WishlistId").Eq(wishlistId),
+			goqu.C("Ownership").Eq(ownership),
+		).ScanVal(&current)
+
+		if err != nil {
+			return err
+		}
+
+		if granted && hasPermission(current, invitation.Permissions) {
+			return nil
+		}
+
+		if !invitation.outstanding(at) {
+			return ErrInvalidInvitation
+		}
+
+		// The count is checked again, in case the invitation was redeemed after it was read.
+		result, err := tx.Update("Invitation").
+			Set(goqu.Record{"Redemptions": invitation.Redemptions + 1}).
+			Where(goqu.C("Id").Eq(invitation.Id), goqu.C("Redemptions").Eq(invitation.Redemptions)).
+			Executor().Exec()
+
+		if err := checkAffected(result, err); err != nil {
+			return errors.Join(ErrInvalidInvitation, err)
+		}
+		invitation.Redemptions++
+
+		return grantPermission(tx, wishlistId, ownership, invitation.Permissions)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// Permanently removes the invitations that expired before the given moment.
+func (repo *InvitationRepository) Purge(before time.Time) (int64, error) {
+	result, err := repo.db.Delete("Invitation").
+		Where(goqu.C("ExpiresAt").Lt(before.UTC())).
+		Executor().Exec()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Keeps invitations in memory.
+type MemoryInvitationRepository struct {
+	mutex       *sync.RWMutex
+	invitations []Invitation
+	wishlists   *MemoryWishlistRepository
+}
+
+func NewMemoryInvitationRepository(wishlists *MemoryWishlistRepository) *MemoryInvitationRepository {
+	return &MemoryInvitationRepository{
+		mutex:       &sync.RWMutex{},
+		invitations: []Invitation{},
+		wishlists:   wishlists,
+	}
+}
+
+// Stores the invitation and generates its ID and token. Only a hash of the token is stored, so the
+// returned invitation is the only place the token can be read from.
+func (repo *MemoryInvitationRepository) AddInvitation(invitation Invitation) (*Invitation, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	invitation.Id = NewId()
+	invitation.CreatedAt = time.Now().UTC()
+	invitation.ExpiresAt = invitation.ExpiresAt.UTC()
+	invitation.Redemptions = 0
+
+	token := NewId()
+	invitation.Token = ""
+	invitation.TokenHash = HashPassword(token)
+	repo.invitations = append(repo.invitations, invitation)
+
+	invitation.Token = token
+	return &invitation, nil
+}
+
+// Returns the invitations of the wishlist that can still be redeemed at the given moment, newest first.
+func (repo *MemoryInvitationRepository) GetInvitations(wishlistId string, at time.Time) ([]Invitation, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	invitations := []Invitation{}
+	for _, invitation := range slices.Backward(repo.invitations) {
+		if invitation.WishlistId == wishlistId && invitation.outstanding(at) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	return invitations, nil
+}
+
+// Revokes the invitation, so it can no longer be redeemed. Returns os.ErrNotExist if the wishlist
+// has no invitation with the given ID.
+func (repo *MemoryInvitationRepository) DeleteInvitation(wishlistId string, id string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	count := len(repo.invitations)
+	repo.invitations = slices.DeleteFunc(repo.invitations, func(invitation Invitation) bool {
+		return invitation.WishlistId == wishlistId && invitation.Id == id
+	})
+
+	if len(repo.invitations) == count {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Redeems the invitation of the wishlist with the given token, and gives the owner the permissions of
+// the invitation. Owners that already have those permissions do not use up the invitation. Returns
+// ErrInvalidInvitation if the invitation can not be redeemed at the given moment.
+func (repo *MemoryInvitationRepository) RedeemInvitation(wishlistId string, token string, ownership string, at time.Time) (*Invitation, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	index := slices.IndexFunc(repo.invitations, func(invitation Invitation) bool {
+		return invitation.WishlistId == wishlistId && invitation.TokenHash == HashPassword(token)
+	})
+
+	if index < 0 {
+		return nil, ErrInvalidInvitation
+	}
+
+	repo.wishlists.mutex.Lock()
+	defer repo.wishlists.mutex.Unlock()
+
+	if _, ok := repo.wishlists.models[wishlistId]; !ok {
+		return nil, ErrInvalidInvitation
+	}
+
+	viewer, ok := repo.wishlists.viewers[viewerKey{wishlistId, ownership}]
+	if ok && hasPermission(viewer.Permissions, repo.invitations[index].Permissions) {
+		invitation := repo.invitations[index]
+		return &invitation, nil
+	}
+
+	if !repo.invitations[index].outstanding(at) {
+		return nil, ErrInvalidInvitation
+	}
+
+	repo.invitations[index].Redemptions++
+	repo.wishlists.grant(wishlistId, ownership, repo.invitations[index].Permissions)
+
+	invitation := repo.invitations[index]
+	return &invitation, nil
+}
+
+// Permanently removes the invitations that expired before the given moment, and those of
+// wishlists that were purged.
+func (repo *MemoryInvitationRepository) Purge(before time.Time) (int64, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.wishlists.mutex.RLock()
+	defer repo.wishlists.mutex.RUnlock()
+
+	count := len(repo.invitations)
+	repo.invitations = slices.DeleteFunc(repo.invitations, func(invitation Invitation) bool {
+		_, ok := repo.wishlists.models[invitation.WishlistId]
+		return !ok || invitation.ExpiresAt.Before(before)
+	})
+
+	return int64(count - len(repo.invitations)), nil
+}
+
+// Copies the stored invitations, and returns a function that restores them to the copy.
+func (repo *MemoryInvitationRepository) snapshot() (restore func()) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	invitations := slices.Clone(repo.invitations)
+
+	return func() {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.invitations = invitations
+	}
+}
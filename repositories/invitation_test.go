@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedeemInvitation(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+		now := time.Now()
+
+		invitation, err := repos.Invitations.AddInvitation(Invitation{
+			WishlistId:     wishlist.Id,
+			Permissions:    "VIEW",
+			ExpiresAt:      now.Add(time.Hour),
+			MaxRedemptions: 2,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Guests that already have access do not use up the invitation.
+		for _, guest := range []string{"guest", "guest", "other guest"} {
+			if _, err := repos.Invitations.RedeemInvitation(wishlist.Id, invitation.Token, guest, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := repos.Invitations.RedeemInvitation(wishlist.Id, invitation.Token, "third guest", now); !errors.Is(err, ErrInvalidInvitation) {
+			t.Errorf("redeeming a used up invitation returned %v, want %v", err, ErrInvalidInvitation)
+		}
+
+		if permission, err := repos.Wishlists.GetPermission(wishlist.Id, "other guest"); err != nil || permission != "VIEW" {
+			t.Errorf("permission = %q with error %v, want VIEW", permission, err)
+		}
+
+		expiring, err := repos.Invitations.AddInvitation(Invitation{WishlistId: wishlist.Id, Permissions: "EDIT", ExpiresAt: now.Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Invitations.RedeemInvitation(wishlist.Id, expiring.Token, "guest", now.Add(time.Hour)); !errors.Is(err, ErrInvalidInvitation) {
+			t.Errorf("redeeming an expired invitation returned %v, want %v", err, ErrInvalidInvitation)
+		}
+	})
+}
@@ -1,28 +1,247 @@
 package repository
 
 import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/doug-martin/goqu/v9"
 )
 
 type Item struct {
 	Model[string]
-	WishlistId  string `json:"-" db:"WishlistId"`
+	WishlistId  string `json:"-" db:"WishlistId" goqu:"skipupdate"`
 	Url         string `json:"url" db:"Url"`
 	Name        string `json:"name" db:"Name"`
 	Description string `json:"description" db:"Description"`
 	Image       string `json:"image" db:"Image"`
+	// The amount of units the owner wants, which guests can reserve.
+	Quantity int `json:"quantity" db:"Quantity"`
+	// The price of a single unit, if it is known, in the currency of the item.
+	Price    *Amount `json:"price" db:"Price"`
+	Currency string  `json:"currency" db:"Currency"`
+	// How much the owner wants the item, either PriorityMustHave or PriorityNiceToHave.
+	Priority string `json:"priority" db:"Priority"`
+	// Details the owner adds for their guests, like the size or colour they want.
+	Notes string `json:"notes" db:"Notes"`
+	// The amount of units the owner already bought or received.
+	Received int `json:"received" db:"Received"`
+	// Where the item is shown on its wishlist, lower positions first. Positions are only changed by
+	// reordering the items of the wishlist.
+	Position int64 `json:"position" db:"Position" goqu:"skipupdate"`
+}
+
+// How much the owner of an item wants it.
+const (
+	PriorityMustHave   = "must-have"
+	PriorityNiceToHave = "nice-to-have"
+)
+
+const (
+	// The maximum amount of units of an item that can be wanted or received.
+	maxQuantity = 1000
+	// The maximum length of the notes of an item.
+	maxNotesLength = 1000
+)
+
+var (
+	ErrInvalidOrder    = errors.New("the order can only contain items of the wishlist, each of them once")
+	ErrInvalidPrice    = errors.New("prices need an ISO 4217 currency code, like EUR or USD")
+	ErrInvalidQuantity = fmt.Errorf("the quantity needs to be between 1 and %d", maxQuantity)
+	ErrInvalidReceived = fmt.Errorf("the received quantity needs to be between 0 and %d", maxQuantity)
+	ErrInvalidPriority = fmt.Errorf("the priority needs to be %s or %s", PriorityMustHave, PriorityNiceToHave)
+	ErrNotesTooLong    = fmt.Errorf("notes can be at most %d characters long", maxNotesLength)
+)
+
+// Currencies are identified by their ISO 4217 code.
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Returns whether the currency is an ISO 4217 code.
+func IsCurrency(currency string) bool {
+	return currencyCode.MatchString(currency)
+}
+
+// Decodes the item, wanting a single unit of it that is nice to have when the quantity and
+// priority are left out.
+func (item *Item) UnmarshalJSON(data []byte) error {
+	type plain Item
+	decoded := plain{Quantity: 1, Priority: PriorityNiceToHave}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	decoded.Currency = strings.ToUpper(strings.TrimSpace(decoded.Currency))
+	*item = Item(decoded)
+	return nil
+}
+
+// Verifies the fields the owner controls have valid values.
+func (item Item) Validate() error {
+	switch {
+	case item.Quantity < 1 || item.Quantity > maxQuantity:
+		return ErrInvalidQuantity
+	case item.Received < 0 || item.Received > maxQuantity:
+		return ErrInvalidReceived
+	case item.Priority != PriorityMustHave && item.Priority != PriorityNiceToHave:
+		return ErrInvalidPriority
+	case len([]rune(item.Notes)) > maxNotesLength:
+		return ErrNotesTooLong
+	case item.Price != nil && !IsCurrency(item.Currency):
+		return ErrInvalidPrice
+	}
+	return nil
+}
+
+func (item Item) AuditScope() string {
+	return item.WishlistId
+}
+
+// The distance between the positions of neighbouring items that are numbered from scratch. The
+// gaps leave room to move items between their neighbours without changing any other item.
+const positionGap = 1024
+
+// Sorts the items of a wishlist by their position, unless the query sorts them otherwise.
+func inWishlistOrder(query Query) Query {
+	if len(query.Sort) <= 0 {
+		query.Sort = []Sort{{Field: "position"}, {Field: "createdAt"}}
+	}
+	return query
+}
+
+// Compares items by their position on their wishlist.
+func compareItemPositions(a Item, b Item) int {
+	return cmp.Or(cmp.Compare(a.Position, b.Position), a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+}
+
+// Returns the new positions of the items that move when the items with the given IDs are placed
+// directly after the item with the ID after, or first when after is empty, in the given order.
+// The items need to be sorted by their current position. Only the given items move, unless there
+// is no room left between their new neighbours, in which case all items are numbered from scratch.
+func reorder(items []Item, ids []string, after string) (map[string]int64, error) {
+	known := map[string]bool{}
+	for _, item := range items {
+		known[item.Id] = true
+	}
+
+	moved := map[string]bool{}
+	for _, id := range ids {
+		if !known[id] || moved[id] {
+			return nil, ErrInvalidOrder
+		}
+		moved[id] = true
+	}
+
+	if len(after) > 0 && (!known[after] || moved[after]) {
+		return nil, ErrInvalidOrder
+	}
+
+	rest := slices.DeleteFunc(slices.Clone(items), func(item Item) bool {
+		return moved[item.Id]
+	})
+
+	slot := 0
+	if len(after) > 0 {
+		slot = slices.IndexFunc(rest, func(item Item) bool { return item.Id == after }) + 1
+	}
+
+	positions := map[string]int64{}
+	count := int64(len(ids))
+	for i, id := range ids {
+		step := int64(i + 1)
+		switch {
+		case len(rest) <= 0:
+			positions[id] = positionGap * step
+		case slot <= 0:
+			positions[id] = rest[0].Position - positionGap*(count+1-step)
+		case slot >= len(rest):
+			positions[id] = rest[slot-1].Position + positionGap*step
+		default:
+			lower, upper := rest[slot-1].Position, rest[slot].Position
+			if (upper-lower)/(count+1) <= 0 {
+				return renumber(slices.Concat(rest[:slot], pick(items, ids), rest[slot:])), nil
+			}
+			positions[id] = lower + (upper-lower)/(count+1)*step
+		}
+	}
+	return positions, nil
+}
+
+// Returns the items with the given IDs, in the order of the IDs.
+func pick(items []Item, ids []string) []Item {
+	picked := make([]Item, len(ids))
+	for i, id := range ids {
+		picked[i] = items[slices.IndexFunc(items, func(item Item) bool { return item.Id == id })]
+	}
+	return picked
+}
+
+// Numbers the items from scratch in the given order, and returns the positions that changed.
+func renumber(items []Item) map[string]int64 {
+	positions := map[string]int64{}
+	for i, item := range items {
+		if position := positionGap * int64(i+1); position != item.Position {
+			positions[item.Id] = position
+		}
+	}
+	return positions
+}
+
+// An amount of money in hundredths of a currency unit, which keeps sums of amounts exact. It is
+// written to JSON as a decimal number, like 12.5 for 1250.
+type Amount int64
+
+func (amount Amount) String() string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
+
+func (amount Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strings.TrimSuffix(strings.TrimRight(amount.String(), "0"), ".")), nil
+}
+
+// Decodes a decimal number with at most two decimals. Negative amounts are rejected.
+func (amount *Amount) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseAmount(string(data))
+	if err != nil {
+		return err
+	}
+
+	*amount = parsed
+	return nil
+}
+
+// Amounts are written with a point as the decimal separator and at most two decimals.
+var amountFormat = regexp.MustCompile(`^([0-9]{1,15})(?:\.([0-9]{1,2}))?$`)
+
+// Parses a non-negative decimal number with at most two decimals and a point as the decimal separator.
+func ParseAmount(value string) (Amount, error) {
+	match := amountFormat.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	units, _ := strconv.ParseInt(match[1], 10, 64)
+	cents, _ := strconv.ParseInt((match[2] + "00")[:2], 10, 64)
+	return Amount(units*100 + cents), nil
 }
 
 type ItemRepository struct {
-	*AbstractSQLiteRepository[Item, string]
+	*AbstractSQLRepository[Item, string]
 }
 
-func NewItemRepository(db *goqu.Database) *ItemRepository {
+func NewItemRepository(db Database) *ItemRepository {
 	repo := &ItemRepository{
-		&AbstractSQLiteRepository[Item, string]{
+		&AbstractSQLRepository[Item, string]{
 			db:     db,
 			dbName: "Item",
 			empty:  Item{},
+			newId:  NewId,
 		},
 	}
 	return repo
@@ -31,3 +250,91 @@ func NewItemRepository(db *goqu.Database) *ItemRepository {
 func (repo *ItemRepository) RemoveId(item *Item) {
 	item.Id = ""
 }
+
+// Stores the item after the other items of its wishlist.
+func (repo *ItemRepository) Add(item Item, actor string) (*Item, error) {
+	var added *Item
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		var last sql.NullInt64
+		_, err := tx.From("Item").
+			Select(goqu.MAX("Position")).
+			Where(goqu.C("WishlistId").Eq(item.WishlistId)).
+			ScanVal(&last)
+
+		if err != nil {
+			return err
+		}
+
+		item.Position = positionGap
+		if last.Valid {
+			item.Position += last.Int64
+		}
+
+		added, err = NewItemRepository(tx).AbstractSQLRepository.Add(item, actor)
+		return err
+	})
+
+	return added, err
+}
+
+// Places the items with the given IDs directly after the item with the ID after, or first when
+// after is empty, in the given order. The other items of the wishlist keep their order. Returns
+// ErrInvalidOrder if an ID is not one of the items of the wishlist that are not in the trash.
+func (repo *ItemRepository) Reorder(wishlistId string, ids []string, after string) error {
+	return withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		// Writing to the wishlist first locks it, so concurrent reorderings wait for each other.
+		result, err := tx.Update("Wishlist").
+			Set(goqu.Record{"Version": goqu.C("Version")}).
+			Where(goqu.C("Id").Eq(wishlistId)).
+			Executor().Exec()
+
+		if err := checkAffected(result, err); err != nil {
+			return err
+		}
+
+		items := []Item{}
+		err = tx.From("Item").
+			Where(goqu.C("WishlistId").Eq(wishlistId), goqu.C("DeletedAt").IsNull()).
+			Order(goqu.C("Position").Asc(), goqu.C("CreatedAt").Asc(), goqu.C("Id").Asc()).
+			ScanStructs(&items)
+
+		if err != nil {
+			return err
+		}
+
+		positions, err := reorder(items, ids, after)
+		if err != nil {
+			return err
+		}
+
+		for id, position := range positions {
+			_, err := tx.Update("Item").Set(goqu.Record{"Position": position}).Where(goqu.C("Id").Eq(id)).Executor().Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Permanently deletes the items that were moved to the trash before the given moment, together with
+// their reservations and pledges.
+func (repo *ItemRepository) Purge(before time.Time) (int64, error) {
+	var count int64
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		purged := tx.From("Item").Select("Id").Where(goqu.C("DeletedAt").Lt(before.UTC()))
+		if _, err := tx.Delete("Reservation").Where(goqu.C("ItemId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("Pledge").Where(goqu.C("ItemId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		var err error
+		count, err = NewItemRepository(tx).AbstractSQLRepository.Purge(before)
+		return err
+	})
+
+	return count, err
+}
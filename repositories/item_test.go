@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Returns the names of the items of the wishlist, in the order they are shown in.
+func itemOrder(t *testing.T, repos *Repositories, wishlistId string) string {
+	t.Helper()
+
+	page, err := repos.Wishlists.GetItems(wishlistId, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	positions := map[int64]bool{}
+	for _, item := range page.Data {
+		if positions[item.Position] {
+			t.Errorf("several items are at position %d", item.Position)
+		}
+		positions[item.Position] = true
+	}
+	return strings.Join(itemNames(page), " ")
+}
+
+func TestReorder(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, items := addWishlist(t, repos, "owner", "Birthday", "A", "B", "C", "D")
+		other, _ := addWishlist(t, repos, "owner", "Christmas", "E")
+		a, b, c, d := items[0].Id, items[1].Id, items[2].Id, items[3].Id
+
+		reorder := func(ids []string, after string, want string) {
+			t.Helper()
+
+			if err := repos.Items.Reorder(wishlist.Id, ids, after); err != nil {
+				t.Fatal(err)
+			}
+			if order := itemOrder(t, repos, wishlist.Id); order != want {
+				t.Errorf("order = %s, want %s", order, want)
+			}
+		}
+
+		reorder([]string{d}, "", "D A B C")
+		reorder([]string{a, c}, b, "D B A C")
+		reorder([]string{d}, c, "B A C D")
+
+		// Moving items between the same neighbours uses up the room between them, after which
+		// every item is numbered again.
+		for range 12 {
+			reorder([]string{c}, b, "B C A D")
+			reorder([]string{a}, b, "B A C D")
+		}
+
+		for _, ids := range [][]string{{a, a}, {a, "unknown"}, {a, b}} {
+			if err := repos.Items.Reorder(wishlist.Id, ids, b); !errors.Is(err, ErrInvalidOrder) {
+				t.Errorf("reordering %v after %s returned %v, want %v", ids, b, err, ErrInvalidOrder)
+			}
+		}
+
+		if err := repos.Items.Reorder(other.Id, []string{a}, ""); !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("moving an item to another wishlist returned %v, want %v", err, ErrInvalidOrder)
+		}
+	})
+}
+
+func TestConcurrentReorder(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		names := []string{"A", "B", "C", "D", "E"}
+		wishlist, items := addWishlist(t, repos, "owner", "Birthday", names...)
+
+		var wait sync.WaitGroup
+		for i := range 20 {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				moved := items[i%len(items)].Id
+				if err := repos.Items.Reorder(wishlist.Id, []string{moved}, ""); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wait.Wait()
+
+		// Every item is still shown once, at a position of its own.
+		order := strings.Fields(itemOrder(t, repos, wishlist.Id))
+		slices.Sort(order)
+		if !slices.Equal(order, names) {
+			t.Errorf("items = %v after reordering them concurrently, want %v", order, names)
+		}
+	})
+}
+
+func TestDecodeItem(t *testing.T) {
+	item := Item{}
+	if err := json.Unmarshal([]byte(`{"name": "Bricks", "currency": " eur "}`), &item); err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Quantity != 1 || item.Priority != PriorityNiceToHave || item.Currency != "EUR" {
+		t.Errorf("item = %+v, want a single unit that is nice to have, priced in EUR", item)
+	}
+}
+
+func TestValidateItem(t *testing.T) {
+	price := Amount(1250)
+	valid := Item{Quantity: 2, Received: 1, Priority: PriorityMustHave, Notes: "Size 42", Price: &price, Currency: "EUR"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("validating %+v returned %v", valid, err)
+	}
+
+	invalid := map[error]func(item *Item){
+		ErrInvalidQuantity: func(item *Item) { item.Quantity = 0 },
+		ErrInvalidReceived: func(item *Item) { item.Received = maxQuantity + 1 },
+		ErrInvalidPriority: func(item *Item) { item.Priority = "someday" },
+		ErrNotesTooLong:    func(item *Item) { item.Notes = strings.Repeat("a", maxNotesLength+1) },
+		ErrInvalidPrice:    func(item *Item) { item.Currency = "" },
+	}
+
+	for want, change := range invalid {
+		item := valid
+		change(&item)
+		if err := item.Validate(); !errors.Is(err, want) {
+			t.Errorf("validating %+v returned %v, want %v", item, err, want)
+		}
+	}
+}
@@ -0,0 +1,740 @@
+package repository
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+	arrays "wishlist-backend/utils/array"
+)
+
+// An abstract repository that keeps its models in memory. It implements the same
+// Repository[T, I] interface as the SQL repositories, so it can be used in their place
+// when no database is available.
+//
+// The repository is safe for concurrent use. Models are copied on the way in and out,
+// so callers cannot modify the stored values.
+//
+// Generic[T] is the type of the model of this repository.
+//
+// Generic[I] is the type of the model's ID of this repository.
+type AbstractMemoryRepository[T interface{}, I comparable] struct {
+	Repository[T, I]
+	mutex  *sync.RWMutex
+	models map[I]T
+	// The IDs of the models in the order they were added in.
+	ids   []I
+	newId func() I
+	// The name of the models in the audit log, the same as the name of their database table.
+	name  string
+	audit *MemoryAuditRepository
+}
+
+func newAbstractMemoryRepository[T interface{}, I comparable](name string, audit *MemoryAuditRepository, newId func() I) *AbstractMemoryRepository[T, I] {
+	return &AbstractMemoryRepository[T, I]{
+		mutex:  &sync.RWMutex{},
+		models: map[I]T{},
+		ids:    []I{},
+		newId:  newId,
+		name:   name,
+		audit:  audit,
+	}
+}
+
+// Searches its memory for the model with the given ID.
+func (repo *AbstractMemoryRepository[T, I]) GetById(id I) (*T, error) {
+	return repo.getById(id, false)
+}
+
+// Returns a page of the stored models.
+func (repo *AbstractMemoryRepository[T, I]) GetAll(query Query) (*Page[T], error) {
+	return paginateSlice(repo.filter(func(model T) bool { return getDeletedAt(model) == nil }), query)
+}
+
+// Replaces a stored model. If an ID is provided as the second value, it should take precedent over any IDs in the model.
+//
+// Fields tagged with `goqu:"skipupdate"` keep their stored value. The version of the model is
+// incremented, and has to match the stored version unless it is 0.
+func (repo *AbstractMemoryRepository[T, I]) Update(o T, id *I, actor string) (*T, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	searchId := getId[T, I](o)
+	if id != nil {
+		searchId = *id
+	}
+
+	stored, ok := repo.models[searchId]
+	if !ok || getDeletedAt(stored) != nil {
+		return nil, os.ErrNotExist
+	}
+
+	if version := getVersion(o); version != 0 && version != getVersion(stored) {
+		return nil, ErrVersionConflict
+	}
+
+	keepSkippedFields(stored, &o)
+	setVersion(&o, getVersion(stored)+1)
+	if err := repo.record(actor, AuditUpdate, &stored, &o); err != nil {
+		return nil, err
+	}
+
+	repo.models[searchId] = o
+	return &o, nil
+}
+
+// Stores a model. IDs will be auto generated, provided IDs should be ignored.
+func (repo *AbstractMemoryRepository[T, I]) Add(o T, actor string) (*T, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	id := repo.newId()
+	setId(&o, id)
+	setCreatedAt(&o, time.Now().UTC())
+	setVersion(&o, 1)
+	setDeletedAt(&o, nil)
+	if err := repo.record(actor, AuditAdd, nil, &o); err != nil {
+		return nil, err
+	}
+
+	repo.models[id] = o
+	repo.ids = append(repo.ids, id)
+	return &o, nil
+}
+
+// Moves the model with the given ID to the trash.
+func (repo *AbstractMemoryRepository[T, I]) DeleteById(id I, actor string) error {
+	deletedAt := time.Now().UTC()
+	return repo.setDeletedAt(id, actor, false, &deletedAt)
+}
+
+// Searches the trash for the model with the given ID.
+func (repo *AbstractMemoryRepository[T, I]) GetDeletedById(id I) (*T, error) {
+	return repo.getById(id, true)
+}
+
+// Moves the model with the given ID out of the trash.
+func (repo *AbstractMemoryRepository[T, I]) Restore(id I, actor string) error {
+	return repo.setDeletedAt(id, actor, true, nil)
+}
+
+// Permanently removes the models that were moved to the trash before the given moment.
+func (repo *AbstractMemoryRepository[T, I]) Purge(before time.Time) (int64, error) {
+	return repo.remove(func(model T) bool {
+		deletedAt := getDeletedAt(model)
+		return deletedAt != nil && deletedAt.Before(before)
+	}), nil
+}
+
+func (repo *AbstractMemoryRepository[T, I]) RemoveId(o *T) {
+	var empty I
+	setId(o, empty)
+}
+
+func (repo *AbstractMemoryRepository[T, I]) getById(id I, deleted bool) (*T, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	model, ok := repo.models[id]
+	if !ok || (getDeletedAt(model) != nil) != deleted {
+		return nil, os.ErrNotExist
+	}
+	return &model, nil
+}
+
+// Sets the DeletedAt field of the model with the given ID, if the model is in the trash
+// or not, as indicated by deleted.
+//
+// Returns os.ErrNotExist if no such model exists.
+func (repo *AbstractMemoryRepository[T, I]) setDeletedAt(id I, actor string, deleted bool, deletedAt *time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	model, ok := repo.models[id]
+	if !ok || (getDeletedAt(model) != nil) != deleted {
+		return os.ErrNotExist
+	}
+
+	// Like in the SQL repositories, deleted models are recorded as they were before they were moved to the trash.
+	var err error
+	if deleted {
+		setDeletedAt(&model, deletedAt)
+		err = repo.record(actor, AuditRestore, nil, &model)
+	} else {
+		err = repo.record(actor, AuditDelete, &model, nil)
+		setDeletedAt(&model, deletedAt)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	repo.models[id] = model
+	return nil
+}
+
+// Records a change to a model in the audit log.
+func (repo *AbstractMemoryRepository[T, I]) record(actor string, action string, before *T, after *T) error {
+	entry, err := newAuditEntry(actor, action, repo.name, before, after)
+	if err != nil {
+		return err
+	}
+
+	repo.audit.record(entry)
+	return nil
+}
+
+// Moves the model with the given ID from one state to another, and returns the audit entry of that change.
+func (repo *AbstractMemoryRepository[T, I]) transition(entityId string, from JSON, to JSON, actor string, action string) (*AuditEntry, error) {
+	id, ok := any(entityId).(I)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	// The model was purged in the meantime.
+	current, ok := repo.models[id]
+	if !ok {
+		return nil, ErrVersionConflict
+	}
+
+	next, err := nextState(current, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := newAuditEntry(actor, action, repo.name, live(&current), live(next))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.models[id] = *next
+	return entry, nil
+}
+
+// Copies the stored models, and returns a function that restores them to the copy.
+func (repo *AbstractMemoryRepository[T, I]) snapshot() (restore func()) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	models := maps.Clone(repo.models)
+	ids := slices.Clone(repo.ids)
+
+	return func() {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.models = models
+		repo.ids = ids
+	}
+}
+
+// Returns copies of all stored models that pass the test, in the order they were added in.
+// Models in the trash are included.
+func (repo *AbstractMemoryRepository[T, I]) filter(test func(T) bool) []T {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	models := []T{}
+	for _, id := range repo.ids {
+		if model := repo.models[id]; test(model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// Permanently removes all stored models that pass the test. Returns the amount of removed models.
+func (repo *AbstractMemoryRepository[T, I]) remove(test func(T) bool) int64 {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var count int64
+	ids := []I{}
+	for _, id := range repo.ids {
+		if !test(repo.models[id]) {
+			ids = append(ids, id)
+			continue
+		}
+
+		delete(repo.models, id)
+		count++
+	}
+
+	repo.ids = ids
+	return count
+}
+
+// Returns whether a model with the given ID is stored, in or outside of the trash.
+func (repo *AbstractMemoryRepository[T, I]) exists(id I) bool {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	_, ok := repo.models[id]
+	return ok
+}
+
+type MemoryItemRepository struct {
+	*AbstractMemoryRepository[Item, string]
+	wishlists *MemoryWishlistRepository
+}
+
+// Creates an empty item repository. Items can only be added to wishlists that exist in
+// the given wishlist repository.
+func NewMemoryItemRepository(wishlists *MemoryWishlistRepository, audit *MemoryAuditRepository) *MemoryItemRepository {
+	return &MemoryItemRepository{
+		AbstractMemoryRepository: newAbstractMemoryRepository[Item]("Item", audit, NewId),
+		wishlists:                wishlists,
+	}
+}
+
+// Stores the item after the other items of its wishlist.
+func (repo *MemoryItemRepository) Add(item Item, actor string) (*Item, error) {
+	if !repo.wishlists.exists(item.WishlistId) {
+		return nil, errors.New("wishlist " + item.WishlistId + " does not exist")
+	}
+
+	item.Position = positionGap
+	for _, stored := range repo.filter(func(stored Item) bool { return stored.WishlistId == item.WishlistId }) {
+		item.Position = max(item.Position, stored.Position+positionGap)
+	}
+	return repo.AbstractMemoryRepository.Add(item, actor)
+}
+
+// Places the items with the given IDs directly after the item with the ID after, or first when
+// after is empty, in the given order. The other items of the wishlist keep their order. Returns
+// ErrInvalidOrder if an ID is not one of the items of the wishlist that are not in the trash.
+func (repo *MemoryItemRepository) Reorder(wishlistId string, ids []string, after string) error {
+	if !repo.wishlists.exists(wishlistId) {
+		return os.ErrNotExist
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	items := []Item{}
+	for _, item := range repo.models {
+		if item.WishlistId == wishlistId && item.DeletedAt == nil {
+			items = append(items, item)
+		}
+	}
+	slices.SortFunc(items, compareItemPositions)
+
+	positions, err := reorder(items, ids, after)
+	if err != nil {
+		return err
+	}
+
+	for id, position := range positions {
+		item := repo.models[id]
+		item.Position = position
+		repo.models[id] = item
+	}
+	return nil
+}
+
+// The key of a viewer of a wishlist.
+type viewerKey struct {
+	wishlistId string
+	ownership  string
+}
+
+type MemoryWishlistRepository struct {
+	*AbstractMemoryRepository[Wishlist, string]
+	items        *MemoryItemRepository
+	reservations *MemoryReservationRepository
+	pledges      *MemoryPledgeRepository
+	viewers      map[viewerKey]WishlistViewer
+	// The viewers in the order they were registered in.
+	viewerKeys []viewerKey
+}
+
+// Creates an empty wishlist repository.
+func NewMemoryWishlistRepository(audit *MemoryAuditRepository) *MemoryWishlistRepository {
+	return &MemoryWishlistRepository{
+		AbstractMemoryRepository: newAbstractMemoryRepository[Wishlist]("Wishlist", audit, NewId),
+		viewers:                  map[viewerKey]WishlistViewer{},
+		viewerKeys:               []viewerKey{},
+	}
+}
+
+// Stores a wishlist and generates its password. Only a hash of the password is stored, so the
+// returned wishlist is the only place the password can be read from.
+func (repo *MemoryWishlistRepository) Add(wishlist Wishlist, actor string) (*Wishlist, error) {
+	password := NewId()
+	wishlist.Password = ""
+	wishlist.PasswordHash = HashPassword(password)
+
+	result, err := repo.AbstractMemoryRepository.Add(wishlist, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Password = password
+	return result, nil
+}
+
+// Replaces the password of the wishlist with a new one and returns the wishlist with that password.
+// When demote is set, viewers that already have edit permissions lose them as well.
+func (repo *MemoryWishlistRepository) RotatePassword(id string, demote bool) (*Wishlist, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	wishlist, ok := repo.models[id]
+	if !ok || wishlist.DeletedAt != nil {
+		return nil, os.ErrNotExist
+	}
+
+	password := NewId()
+	wishlist.PasswordHash = HashPassword(password)
+	repo.models[id] = wishlist
+
+	if demote {
+		for key, viewer := range repo.viewers {
+			if key.wishlistId == id && viewer.Permissions == "EDIT" {
+				viewer.Permissions = "VIEW"
+				repo.viewers[key] = viewer
+			}
+		}
+	}
+
+	wishlist.Password = password
+	return &wishlist, nil
+}
+
+func (repo *MemoryWishlistRepository) GetItems(id string, query Query) (*Page[Item], error) {
+	return paginateSlice(repo.items.filter(func(item Item) bool {
+		return item.WishlistId == id && item.DeletedAt == nil
+	}), inWishlistOrder(query))
+}
+
+// Returns the items of the wishlists the owner owns or was given permissions to. Items and
+// wishlists in the trash are left out.
+func (repo *MemoryWishlistRepository) GetAccessibleItems(ownership string, query Query) (*Page[Item], error) {
+	repo.mutex.RLock()
+	accessible := map[string]bool{}
+	for id, wishlist := range repo.models {
+		_, isViewer := repo.viewers[viewerKey{id, ownership}]
+		accessible[id] = wishlist.DeletedAt == nil && (wishlist.Ownership == ownership || isViewer)
+	}
+	repo.mutex.RUnlock()
+
+	return paginateSlice(repo.items.filter(func(item Item) bool {
+		return accessible[item.WishlistId] && item.DeletedAt == nil
+	}), query)
+}
+
+func (repo *MemoryWishlistRepository) GetOwnedWishlists(ownership string) ([]Wishlist, error) {
+	return repo.filter(func(wishlist Wishlist) bool {
+		return wishlist.Ownership == ownership && wishlist.DeletedAt == nil
+	}), nil
+}
+
+func (repo *MemoryWishlistRepository) GetSavedWishlists(ownership string) ([]WishlistPermissioned, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	wishlists := []WishlistPermissioned{}
+	for _, key := range repo.viewerKeys {
+		if key.ownership != ownership || repo.models[key.wishlistId].DeletedAt != nil {
+			continue
+		}
+
+		wishlists = append(wishlists, WishlistPermissioned{
+			Wishlist:    repo.models[key.wishlistId],
+			Permissions: repo.viewers[key].Permissions,
+		})
+	}
+	return wishlists, nil
+}
+
+func (repo *MemoryWishlistRepository) GetPermission(wishlistId string, ownership string) (string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	viewer, ok := repo.viewers[viewerKey{wishlistId, ownership}]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return viewer.Permissions, nil
+}
+
+func (repo *MemoryWishlistRepository) RegisterPermission(wishlistId string, ownership string, password string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	wishlist, ok := repo.models[wishlistId]
+	if !ok || wishlist.DeletedAt != nil {
+		return os.ErrNotExist
+	}
+
+	// If password is present, verify password and change permission from viewer to editor.
+	permissions := "VIEW"
+	if len(password) > 0 {
+		if !verifyPassword(wishlist.PasswordHash, password) {
+			return ErrInvalidPassword
+		}
+		permissions = "EDIT"
+	}
+
+	repo.grant(wishlistId, ownership, permissions)
+	return nil
+}
+
+// Registers the owner as a viewer of the wishlist with the given permissions. Viewers that already
+// have edit permissions keep them. The repository has to be locked by the caller.
+func (repo *MemoryWishlistRepository) grant(wishlistId string, ownership string, permissions string) {
+	key := viewerKey{wishlistId, ownership}
+	viewer, ok := repo.viewers[key]
+	if !ok {
+		repo.viewerKeys = append(repo.viewerKeys, key)
+		viewer.CreatedAt = time.Now().UTC()
+	} else if hasPermission(viewer.Permissions, permissions) {
+		return
+	}
+
+	viewer.WishlistId = wishlistId
+	viewer.Ownership = ownership
+	viewer.Permissions = permissions
+	repo.viewers[key] = viewer
+}
+
+// Returns the viewers of the wishlist, in the order they first opened it.
+func (repo *MemoryWishlistRepository) GetViewers(wishlistId string) ([]WishlistViewer, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	viewers := []WishlistViewer{}
+	for _, key := range repo.viewerKeys {
+		if key.wishlistId == wishlistId {
+			viewers = append(viewers, repo.viewers[key])
+		}
+	}
+	return viewers, nil
+}
+
+// Changes the permissions of a viewer of the wishlist. Returns os.ErrNotExist if the owner
+// is not a viewer of the wishlist.
+func (repo *MemoryWishlistRepository) SetPermission(wishlistId string, ownership string, permissions string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key := viewerKey{wishlistId, ownership}
+	viewer, ok := repo.viewers[key]
+	if !ok {
+		return os.ErrNotExist
+	}
+
+	viewer.Permissions = permissions
+	repo.viewers[key] = viewer
+	return nil
+}
+
+// Revokes all permissions of a viewer of the wishlist. Returns os.ErrNotExist if the owner
+// is not a viewer of the wishlist.
+func (repo *MemoryWishlistRepository) RemoveViewer(wishlistId string, ownership string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	key := viewerKey{wishlistId, ownership}
+	if _, ok := repo.viewers[key]; !ok {
+		return os.ErrNotExist
+	}
+
+	delete(repo.viewers, key)
+	repo.viewerKeys = slices.DeleteFunc(repo.viewerKeys, func(stored viewerKey) bool {
+		return stored == key
+	})
+	return nil
+}
+
+// Moves the wishlists and permissions of one identity to another. When both identities are viewers
+// of the same wishlist, the highest of their permissions is kept.
+func (repo *MemoryWishlistRepository) ClaimOwnership(from string, to string) (*Claimed, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	claimed := &Claimed{}
+	for id, wishlist := range repo.models {
+		if wishlist.Ownership == from {
+			wishlist.Ownership = to
+			repo.models[id] = wishlist
+			claimed.Wishlists++
+		}
+	}
+
+	keys := slices.Clone(repo.viewerKeys)
+	for _, key := range keys {
+		if key.ownership != from {
+			continue
+		}
+
+		viewer := repo.viewers[key]
+		delete(repo.viewers, key)
+		repo.viewerKeys = slices.DeleteFunc(repo.viewerKeys, func(stored viewerKey) bool {
+			return stored == key
+		})
+		claimed.Collaborations++
+
+		if repo.models[key.wishlistId].Ownership == to {
+			continue
+		}
+
+		claimedKey := viewerKey{key.wishlistId, to}
+		if _, ok := repo.viewers[claimedKey]; ok {
+			repo.grant(key.wishlistId, to, viewer.Permissions)
+			continue
+		}
+
+		repo.viewers[claimedKey] = viewer
+		repo.viewerKeys = append(repo.viewerKeys, claimedKey)
+	}
+
+	// Owners do not need permissions for their own wishlists.
+	repo.viewerKeys = slices.DeleteFunc(repo.viewerKeys, func(key viewerKey) bool {
+		if key.ownership == to && repo.models[key.wishlistId].Ownership == to {
+			delete(repo.viewers, key)
+			return true
+		}
+		return false
+	})
+
+	repo.audit.reattribute(from, to)
+	repo.reservations.claim(from, to)
+	repo.pledges.claim(from, to)
+	return claimed, nil
+}
+
+// Returns the wishlists of the owner that are in the trash.
+func (repo *MemoryWishlistRepository) GetDeletedWishlists(ownership string) ([]Wishlist, error) {
+	return repo.filter(func(wishlist Wishlist) bool {
+		return wishlist.Ownership == ownership && wishlist.DeletedAt != nil
+	}), nil
+}
+
+// Returns the items in the trash that belong to wishlists of the owner. Items of wishlists
+// that are in the trash themselves are left out, they are restored together with their wishlist.
+func (repo *MemoryWishlistRepository) GetDeletedItems(ownership string) ([]Item, error) {
+	owned, err := repo.GetOwnedWishlists(ownership)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.items.filter(func(item Item) bool {
+		return item.DeletedAt != nil && slices.ContainsFunc(owned, func(wishlist Wishlist) bool {
+			return wishlist.Id == item.WishlistId
+		})
+	}), nil
+}
+
+// Copies the stored wishlists and viewers, and returns a function that restores them to the copy.
+func (repo *MemoryWishlistRepository) snapshot() (restore func()) {
+	restoreWishlists := repo.AbstractMemoryRepository.snapshot()
+
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	viewers := maps.Clone(repo.viewers)
+	viewerKeys := slices.Clone(repo.viewerKeys)
+
+	return func() {
+		restoreWishlists()
+
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.viewers = viewers
+		repo.viewerKeys = viewerKeys
+	}
+}
+
+// Permanently removes the wishlists that were moved to the trash before the given moment,
+// together with their items, viewers and history.
+func (repo *MemoryWishlistRepository) Purge(before time.Time) (int64, error) {
+	purged := repo.filter(func(wishlist Wishlist) bool {
+		return wishlist.DeletedAt != nil && wishlist.DeletedAt.Before(before)
+	})
+
+	isPurged := func(wishlistId string) bool {
+		return slices.ContainsFunc(purged, func(wishlist Wishlist) bool {
+			return wishlist.Id == wishlistId
+		})
+	}
+
+	repo.items.remove(func(item Item) bool {
+		return isPurged(item.WishlistId)
+	})
+
+	repo.mutex.Lock()
+	repo.viewerKeys = arrays.Filter(repo.viewerKeys, func(key viewerKey) bool {
+		if isPurged(key.wishlistId) {
+			delete(repo.viewers, key)
+			return false
+		}
+		return true
+	})
+	repo.mutex.Unlock()
+
+	repo.audit.remove(isPurged)
+
+	return repo.remove(func(wishlist Wishlist) bool {
+		return isPurged(wishlist.Id)
+	}), nil
+}
+
+// Creates repositories that keep their data in memory. Useful for testing the API without a database.
+func NewMemoryRepositories() *Repositories {
+	audit := NewMemoryAuditRepository()
+	wishlists := NewMemoryWishlistRepository(audit)
+	items := NewMemoryItemRepository(wishlists, audit)
+	wishlists.items = items
+	audit.entities["Wishlist"] = wishlists
+	audit.entities["Item"] = items
+	invitations := NewMemoryInvitationRepository(wishlists)
+	attempts := NewMemoryPasswordAttemptRepository(wishlists)
+	reservations := NewMemoryReservationRepository(items)
+	wishlists.reservations = reservations
+	pledges := NewMemoryPledgeRepository(items)
+	wishlists.pledges = pledges
+
+	repos := &Repositories{
+		Items:            items,
+		Wishlists:        wishlists,
+		Audit:            audit,
+		Search:           NewMemorySearchRepository(wishlists),
+		Users:            NewMemoryUserRepository(),
+		Invitations:      invitations,
+		PasswordAttempts: attempts,
+		Reservations:     reservations,
+		Pledges:          pledges,
+	}
+
+	// Transactions are run one at a time, and undo their changes by restoring a copy of the data.
+	// Changes that are made outside of the transaction while it runs are lost when it is rolled back.
+	transactions := &sync.Mutex{}
+	inTransaction := *repos
+	inTransaction.transaction = func(fn func(repos *Repositories) error) error {
+		return fn(&inTransaction)
+	}
+
+	repos.transaction = func(fn func(repos *Repositories) error) error {
+		transactions.Lock()
+		defer transactions.Unlock()
+
+		restore := []func(){wishlists.snapshot(), items.snapshot(), audit.snapshot(), invitations.snapshot(), reservations.snapshot(), pledges.snapshot()}
+		err := fn(&inTransaction)
+		if err != nil {
+			for _, restore := range restore {
+				restore()
+			}
+		}
+		return err
+	}
+	audit.transaction = repos.transaction
+
+	return repos
+}
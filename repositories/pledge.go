@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+var (
+	ErrNoPrice          = errors.New("the item has no price to contribute to")
+	ErrCurrencyMismatch = errors.New("pledges need to be made in the currency of the item")
+	ErrOverPledged      = errors.New("the pledge is more than is left to fund")
+)
+
+// A promise of a guest to pay part of the price of an item, so several guests can buy an expensive
+// gift together. Pledges are kept from the owner of the wishlist, like reservations.
+type Pledge struct {
+	ItemId string `json:"-" db:"ItemId"`
+	// The identity of the guest that made the pledge.
+	Contributor string `json:"-" db:"Contributor"`
+	Amount      Amount `json:"amount" db:"Amount"`
+	// The currency of the amount, which is the currency the item had when the pledge was made.
+	Currency  string    `json:"currency" db:"Currency"`
+	Message   string    `json:"message" db:"Message"`
+	CreatedAt time.Time `json:"pledgedAt" db:"CreatedAt"`
+}
+
+type PledgeRepository struct {
+	db Database
+}
+
+func NewPledgeRepository(db Database) *PledgeRepository {
+	return &PledgeRepository{db: db}
+}
+
+// Pledges an amount towards the price of the item, replacing the pledge the contributor already
+// made. The currency defaults to the currency of the item. Returns ErrNoPrice if the item has no
+// price, ErrCurrencyMismatch if the currencies differ, ErrOverPledged if the other pledges leave
+// less to fund, and os.ErrNotExist if the item does not exist or is in the trash.
+func (repo *PledgeRepository) Pledge(pledge Pledge) (*Pledge, error) {
+	pledge.CreatedAt = time.Now().UTC()
+
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		// Writing to the item first locks it, so concurrent pledges to the same item wait for
+		// each other and can not fund it twice.
+		result, err := tx.Update("Item").
+			Set(goqu.Record{"Quantity": goqu.C("Quantity")}).
+			Where(goqu.C("Id").Eq(pledge.ItemId), goqu.C("DeletedAt").IsNull()).
+			Executor().Exec()
+
+		if err := checkAffected(result, err); err != nil {
+			return err
+		}
+
+		item := Item{}
+		if _, err := tx.From("Item").Where(goqu.C("Id").Eq(pledge.ItemId)).ScanStruct(&item); err != nil {
+			return err
+		}
+
+		others := []Pledge{}
+		err = tx.From("Pledge").Where(
+			goqu.C("ItemId").Eq(pledge.ItemId),
+			goqu.C("Contributor").Neq(pledge.Contributor),
+		).ScanStructs(&others)
+
+		if err != nil {
+			return err
+		}
+
+		if err := checkPledge(&pledge, item, others); err != nil {
+			return err
+		}
+
+		_, err = tx.Delete("Pledge").Where(
+			goqu.C("ItemId").Eq(pledge.ItemId),
+			goqu.C("Contributor").Eq(pledge.Contributor),
+		).Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Insert("Pledge").Rows(pledge).Executor().Exec()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pledge, nil
+}
+
+// Withdraws the pledge of the contributor. Returns os.ErrNotExist if they made no pledge to the item.
+func (repo *PledgeRepository) WithdrawPledge(itemId string, contributor string) error {
+	result, err := repo.db.Delete("Pledge").
+		Where(goqu.C("ItemId").Eq(itemId), goqu.C("Contributor").Eq(contributor)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Returns the pledges to the items, oldest first.
+func (repo *PledgeRepository) GetPledges(itemIds []string) ([]Pledge, error) {
+	pledges := []Pledge{}
+	if len(itemIds) <= 0 {
+		return pledges, nil
+	}
+
+	err := repo.db.From("Pledge").
+		Where(goqu.C("ItemId").In(itemIds)).
+		Order(goqu.C("CreatedAt").Asc()).
+		ScanStructs(&pledges)
+
+	if err != nil {
+		return nil, err
+	}
+	return pledges, nil
+}
+
+// Moves the pledges of one identity to another. When both pledged to the same item, their
+// pledges are combined if they are in the same currency. Otherwise only the pledge in the
+// currency of the item is kept.
+func claimPledges(tx *goqu.TxDatabase, from string, to string) error {
+	pledges := []Pledge{}
+	if err := tx.From("Pledge").Where(goqu.C("Contributor").Eq(from)).ScanStructs(&pledges); err != nil {
+		return err
+	}
+
+	for _, pledge := range pledges {
+		claimed := goqu.Ex{"ItemId": pledge.ItemId, "Contributor": to}
+		existing := Pledge{}
+		found, err := tx.From("Pledge").Where(claimed).ScanStruct(&existing)
+		if err != nil {
+			return err
+		}
+
+		target := goqu.Ex{"ItemId": pledge.ItemId, "Contributor": from}
+		if !found {
+			_, err = tx.Update("Pledge").Set(goqu.Record{"Contributor": to}).Where(target).Executor().Exec()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if existing.Currency == pledge.Currency {
+			_, err = tx.Update("Pledge").
+				Set(goqu.Record{"Amount": goqu.L(`"Amount" + ?`, pledge.Amount)}).
+				Where(claimed).
+				Executor().Exec()
+		} else {
+			var currency string
+			_, err = tx.From("Item").Select("Currency").Where(goqu.C("Id").Eq(pledge.ItemId)).ScanVal(&currency)
+			if err == nil && pledge.Currency == currency {
+				_, err = tx.Update("Pledge").
+					Set(goqu.Record{"Amount": pledge.Amount, "Currency": pledge.Currency, "Message": pledge.Message}).
+					Where(claimed).
+					Executor().Exec()
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Delete("Pledge").Where(target).Executor().Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verifies the pledge fits in what the other pledges leave of the price of the item, and fills
+// in the currency of the item if the pledge left it out.
+func checkPledge(pledge *Pledge, item Item, others []Pledge) error {
+	if item.Price == nil {
+		return ErrNoPrice
+	}
+
+	if len(pledge.Currency) <= 0 {
+		pledge.Currency = item.Currency
+	}
+
+	if pledge.Currency != item.Currency {
+		return ErrCurrencyMismatch
+	}
+
+	if Pledged(others, item.Currency)+pledge.Amount > *item.Price {
+		return ErrOverPledged
+	}
+	return nil
+}
+
+// Returns the amount the pledges in the currency pledge together. Pledges in other currencies, made
+// before the owner changed the currency of the item, can not be added to them.
+func Pledged(pledges []Pledge, currency string) Amount {
+	var total Amount
+	for _, pledge := range pledges {
+		if pledge.Currency == currency {
+			total += pledge.Amount
+		}
+	}
+	return total
+}
+
+// Keeps pledges in memory.
+type MemoryPledgeRepository struct {
+	mutex   *sync.RWMutex
+	pledges []Pledge
+	items   *MemoryItemRepository
+}
+
+func NewMemoryPledgeRepository(items *MemoryItemRepository) *MemoryPledgeRepository {
+	return &MemoryPledgeRepository{
+		mutex:   &sync.RWMutex{},
+		pledges: []Pledge{},
+		items:   items,
+	}
+}
+
+// Pledges an amount towards the price of the item, replacing the pledge the contributor already
+// made. The currency defaults to the currency of the item. Returns ErrNoPrice if the item has no
+// price, ErrCurrencyMismatch if the currencies differ, ErrOverPledged if the other pledges leave
+// less to fund, and os.ErrNotExist if the item does not exist or is in the trash.
+func (repo *MemoryPledgeRepository) Pledge(pledge Pledge) (*Pledge, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, err := repo.items.GetById(pledge.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	others := slices.DeleteFunc(slices.Clone(repo.pledges), func(stored Pledge) bool {
+		return stored.ItemId != pledge.ItemId || stored.Contributor == pledge.Contributor
+	})
+
+	if err := checkPledge(&pledge, *item, others); err != nil {
+		return nil, err
+	}
+
+	pledge.CreatedAt = time.Now().UTC()
+	repo.pledges = slices.DeleteFunc(repo.pledges, func(stored Pledge) bool {
+		return stored.ItemId == pledge.ItemId && stored.Contributor == pledge.Contributor
+	})
+	repo.pledges = append(repo.pledges, pledge)
+
+	return &pledge, nil
+}
+
+// Withdraws the pledge of the contributor. Returns os.ErrNotExist if they made no pledge to the item.
+func (repo *MemoryPledgeRepository) WithdrawPledge(itemId string, contributor string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	count := len(repo.pledges)
+	repo.pledges = slices.DeleteFunc(repo.pledges, func(pledge Pledge) bool {
+		return pledge.ItemId == itemId && pledge.Contributor == contributor
+	})
+
+	if len(repo.pledges) == count {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Returns the pledges to the items, oldest first.
+func (repo *MemoryPledgeRepository) GetPledges(itemIds []string) ([]Pledge, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	pledges := []Pledge{}
+	for _, pledge := range repo.pledges {
+		if slices.Contains(itemIds, pledge.ItemId) {
+			pledges = append(pledges, pledge)
+		}
+	}
+	return pledges, nil
+}
+
+// Moves the pledges of one identity to another. When both pledged to the same item, their
+// pledges are combined if they are in the same currency. Otherwise only the pledge in the
+// currency of the item is kept.
+func (repo *MemoryPledgeRepository) claim(from string, to string) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, pledge := range repo.pledges {
+		if pledge.Contributor != from {
+			continue
+		}
+
+		index := slices.IndexFunc(repo.pledges, func(stored Pledge) bool {
+			return stored.ItemId == pledge.ItemId && stored.Contributor == to
+		})
+
+		switch {
+		case index < 0:
+			repo.pledges[i].Contributor = to
+			continue
+		case repo.pledges[index].Currency == pledge.Currency:
+			repo.pledges[index].Amount += pledge.Amount
+		default:
+			item, err := repo.items.getById(pledge.ItemId, false)
+			if err != nil {
+				item, err = repo.items.getById(pledge.ItemId, true)
+			}
+
+			if err == nil && item.Currency == pledge.Currency {
+				repo.pledges[index].Amount = pledge.Amount
+				repo.pledges[index].Currency = pledge.Currency
+				repo.pledges[index].Message = pledge.Message
+			}
+		}
+		repo.pledges[i].Amount = 0
+	}
+
+	repo.pledges = slices.DeleteFunc(repo.pledges, func(pledge Pledge) bool {
+		return pledge.Amount <= 0
+	})
+}
+
+// Copies the stored pledges, and returns a function that restores them to the copy.
+func (repo *MemoryPledgeRepository) snapshot() (restore func()) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	pledges := slices.Clone(repo.pledges)
+
+	return func() {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.pledges = pledges
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Returns the pledges to the item by their contributor.
+func pledgesByContributor(t *testing.T, repos *Repositories, itemId string) map[string]Pledge {
+	t.Helper()
+
+	pledges, err := repos.Pledges.GetPledges([]string{itemId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contributors := map[string]Pledge{}
+	for _, pledge := range pledges {
+		contributors[pledge.Contributor] = pledge
+	}
+	return contributors
+}
+
+func TestConcurrentPledges(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+		price := Amount(10000)
+		item := addItem(t, repos, wishlist, Item{Name: "Bicycle", Quantity: 1, Price: &price, Currency: "EUR"})
+
+		var wait sync.WaitGroup
+		errs := make([]error, 6)
+		for i := range errs {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				_, errs[i] = repos.Pledges.Pledge(Pledge{ItemId: item.Id, Contributor: fmt.Sprint("guest ", i), Amount: 3000})
+			}()
+		}
+		wait.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, ErrOverPledged) {
+				t.Error(err)
+			}
+		}
+
+		pledges, err := repos.Pledges.GetPledges([]string{item.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if succeeded != 3 || Pledged(pledges, "EUR") != 9000 {
+			t.Errorf("%d pledges succeeded for %s EUR, want 3 for 90.00 EUR", succeeded, Pledged(pledges, "EUR"))
+		}
+
+		if _, err := repos.Pledges.Pledge(Pledge{ItemId: item.Id, Contributor: "tourist", Amount: 500, Currency: "USD"}); !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("pledging in another currency returned %v, want %v", err, ErrCurrencyMismatch)
+		}
+	})
+}
+
+func TestPledgesAfterCurrencyChange(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+		price := Amount(10000)
+		item := addItem(t, repos, wishlist, Item{Name: "Bicycle", Quantity: 1, Price: &price, Currency: "EUR"})
+
+		for _, contributor := range []string{"anonymous", "account"} {
+			if _, err := repos.Pledges.Pledge(Pledge{ItemId: item.Id, Contributor: contributor, Amount: 4000}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Pledges in the old currency no longer count towards the price.
+		item.Currency = "USD"
+		if _, err := repos.Items.Update(*item, &item.Id, "owner"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Pledges.Pledge(Pledge{ItemId: item.Id, Contributor: "anonymous", Amount: 10000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Wishlists.ClaimOwnership("anonymous", "account"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The pledge in the currency of the item is kept instead of being added to one in another currency.
+		pledges := pledgesByContributor(t, repos, item.Id)
+		if len(pledges) != 1 || pledges["account"].Amount != 10000 || pledges["account"].Currency != "USD" {
+			t.Errorf("pledges = %+v, want 100.00 USD of the account", pledges)
+		}
+	})
+}
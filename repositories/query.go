@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// The amount of models a page contains when the query does not specify a limit.
+const DefaultLimit = 50
+
+// The maximum amount of models a single page can contain.
+const MaxLimit = 200
+
+// Returned when a query refers to fields that do not exist, or contains values that cannot be parsed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+type Operator string
+
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "!="
+	Contains       Operator = "~="
+	GreaterOrEqual Operator = ">="
+	LessOrEqual    Operator = "<="
+)
+
+// Describes which page of models to return, and how to sort and filter them.
+//
+// Fields are referred to by their JSON name. Only fields that are visible in the JSON
+// representation of a model can be sorted and filtered on.
+type Query struct {
+	// The maximum amount of models to return. Defaults to DefaultLimit.
+	Limit int
+	// The cursor of the page to return, as returned in the pagination of the previous page.
+	// An empty cursor returns the first page.
+	Cursor  string
+	Sort    []Sort
+	Filters []Filter
+}
+
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+type Filter struct {
+	Field    string
+	Operator Operator
+	Value    string
+}
+
+// A single page of models.
+type Page[T interface{}] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type Pagination struct {
+	Limit int `json:"limit"`
+	// The cursor of the next page, empty if this is the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// A field of a model that can be sorted and filtered on.
+type queryField struct {
+	name   string
+	column string
+	index  []int
+	kind   reflect.Type
+}
+
+// A query that is validated against a model.
+type resolvedQuery struct {
+	limit  int
+	sort   []resolvedSort
+	filter []resolvedFilter
+	// The sort values of the last model of the previous page, nil for the first page.
+	cursor []reflect.Value
+}
+
+type resolvedSort struct {
+	field      queryField
+	descending bool
+}
+
+type resolvedFilter struct {
+	field    queryField
+	operator Operator
+	value    reflect.Value
+}
+
+// Validates the query against the fields of the model type.
+//
+// Models are always sorted by their ID last, so every model has a unique position
+// the cursor can point to. Without explicit sort keys, models are sorted by creation date.
+func resolveQuery[T interface{}](query Query) (*resolvedQuery, error) {
+	fields := getQueryFields(reflect.TypeFor[T]())
+
+	resolved := &resolvedQuery{limit: query.Limit}
+	if resolved.limit <= 0 {
+		resolved.limit = DefaultLimit
+	}
+	if resolved.limit > MaxLimit {
+		resolved.limit = MaxLimit
+	}
+
+	sort := query.Sort
+	if len(sort) <= 0 {
+		sort = []Sort{{Field: "createdAt"}}
+	}
+	if !slices.ContainsFunc(sort, func(s Sort) bool { return s.Field == "id" }) {
+		sort = append(sort, Sort{Field: "id"})
+	}
+
+	for _, s := range sort {
+		field, ok := fields[s.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot sort on unknown field %s", ErrInvalidQuery, s.Field)
+		}
+		resolved.sort = append(resolved.sort, resolvedSort{field, s.Descending})
+	}
+
+	for _, f := range query.Filters {
+		field, ok := fields[f.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot filter on unknown field %s", ErrInvalidQuery, f.Field)
+		}
+
+		if f.Operator == Contains && field.kind.Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: field %s is not text", ErrInvalidQuery, f.Field)
+		}
+
+		value, err := parseValue(f.Value, field.kind)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s is not a valid value for field %s", ErrInvalidQuery, f.Value, f.Field)
+		}
+		resolved.filter = append(resolved.filter, resolvedFilter{field, f.Operator, value})
+	}
+
+	if len(query.Cursor) > 0 {
+		cursor, err := decodeCursor(query.Cursor, resolved.sort)
+		if err != nil {
+			return nil, fmt.Errorf("%w: the cursor does not match the sort keys", ErrInvalidQuery)
+		}
+		resolved.cursor = cursor
+	}
+
+	return resolved, nil
+}
+
+// Applies the query to the dataset and returns the requested page.
+func paginate[T interface{}](dataset *goqu.SelectDataset, query Query) (*Page[T], error) {
+	resolved, err := resolveQuery[T](query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filter := range resolved.filter {
+		column := goqu.C(filter.field.column)
+		value := filter.value.Interface()
+
+		switch filter.operator {
+		case NotEqual:
+			dataset = dataset.Where(column.Neq(value))
+		case Contains:
+			pattern := "%" + escapeLike(strings.ToLower(filter.value.String())) + "%"
+			dataset = dataset.Where(goqu.L("LOWER(?) LIKE ? ESCAPE '!'", column, pattern))
+		case GreaterOrEqual:
+			dataset = dataset.Where(column.Gte(value))
+		case LessOrEqual:
+			dataset = dataset.Where(column.Lte(value))
+		default:
+			dataset = dataset.Where(column.Eq(value))
+		}
+	}
+
+	if resolved.cursor != nil {
+		// Keyset pagination: a model comes after the cursor if it is equal on the first n
+		// sort keys, and comes after it on the next one.
+		after := []exp.Expression{}
+		for i, sort := range resolved.sort {
+			conditions := []exp.Expression{}
+			for j := 0; j < i; j++ {
+				conditions = append(conditions, goqu.C(resolved.sort[j].field.column).Eq(resolved.cursor[j].Interface()))
+			}
+
+			column := goqu.C(sort.field.column)
+			if sort.descending {
+				conditions = append(conditions, column.Lt(resolved.cursor[i].Interface()))
+			} else {
+				conditions = append(conditions, column.Gt(resolved.cursor[i].Interface()))
+			}
+			after = append(after, goqu.And(conditions...))
+		}
+		dataset = dataset.Where(goqu.Or(after...))
+	}
+
+	for _, sort := range resolved.sort {
+		if sort.descending {
+			dataset = dataset.OrderAppend(goqu.C(sort.field.column).Desc())
+		} else {
+			dataset = dataset.OrderAppend(goqu.C(sort.field.column).Asc())
+		}
+	}
+
+	models := []T{}
+	if err := dataset.Limit(uint(resolved.limit + 1)).ScanStructs(&models); err != nil {
+		return nil, err
+	}
+
+	return newPage(resolved, models)
+}
+
+// Applies the query to the models and returns the requested page.
+func paginateSlice[T interface{}](models []T, query Query) (*Page[T], error) {
+	resolved, err := resolveQuery[T](query)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []T{}
+	for _, model := range models {
+		value := reflect.ValueOf(model)
+		if resolved.matches(value) && (resolved.cursor == nil || resolved.compare(value, resolved.cursor) > 0) {
+			filtered = append(filtered, model)
+		}
+	}
+
+	slices.SortFunc(filtered, func(a, b T) int {
+		return resolved.compare(reflect.ValueOf(a), resolved.sortValues(reflect.ValueOf(b)))
+	})
+
+	if len(filtered) > resolved.limit+1 {
+		filtered = filtered[:resolved.limit+1]
+	}
+
+	return newPage(resolved, filtered)
+}
+
+// Creates the page from the models, which should contain at most a single model more than the limit.
+func newPage[T interface{}](query *resolvedQuery, models []T) (*Page[T], error) {
+	page := &Page[T]{
+		Data:       models,
+		Pagination: Pagination{Limit: query.limit},
+	}
+
+	if len(models) > query.limit {
+		page.Data = models[:query.limit]
+		page.Pagination.HasMore = true
+
+		cursor, err := encodeCursor(query.sortValues(reflect.ValueOf(page.Data[query.limit-1])))
+		if err != nil {
+			return nil, err
+		}
+		page.Pagination.NextCursor = cursor
+	}
+
+	return page, nil
+}
+
+// Returns whether the model passes all filters of the query.
+func (query *resolvedQuery) matches(model reflect.Value) bool {
+	for _, filter := range query.filter {
+		value := model.FieldByIndex(filter.field.index)
+		result := compareValues(value, filter.value)
+
+		var ok bool
+		switch filter.operator {
+		case NotEqual:
+			ok = result != 0
+		case Contains:
+			ok = strings.Contains(strings.ToLower(value.String()), strings.ToLower(filter.value.String()))
+		case GreaterOrEqual:
+			ok = result >= 0
+		case LessOrEqual:
+			ok = result <= 0
+		default:
+			ok = result == 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the values of the sort keys of the model.
+func (query *resolvedQuery) sortValues(model reflect.Value) []reflect.Value {
+	values := []reflect.Value{}
+	for _, sort := range query.sort {
+		values = append(values, model.FieldByIndex(sort.field.index))
+	}
+	return values
+}
+
+// Compares the position of the model to the position described by the sort values.
+func (query *resolvedQuery) compare(model reflect.Value, values []reflect.Value) int {
+	for i, sort := range query.sort {
+		result := compareValues(model.FieldByIndex(sort.field.index), values[i])
+		if sort.descending {
+			result = -result
+		}
+
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// Returns the fields of the model type that can be queried, keyed by their JSON name.
+func getQueryFields(model reflect.Type) map[string]queryField {
+	fields := map[string]queryField{}
+	for _, field := range reflect.VisibleFields(model) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		column := field.Tag.Get("db")
+		if name == "-" || column == "-" || len(name) <= 0 || len(column) <= 0 {
+			continue
+		}
+
+		// Nullable fields cannot be compared reliably.
+		if field.Type.Kind() == reflect.Pointer {
+			continue
+		}
+
+		fields[name] = queryField{name, column, field.Index, field.Type}
+	}
+	return fields
+}
+
+// Parses a value from a query parameter into a value of the given type.
+func parseValue(value string, kind reflect.Type) (reflect.Value, error) {
+	if kind == reflect.TypeFor[time.Time]() {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		return reflect.ValueOf(t.UTC()), err
+	}
+
+	parsed := reflect.New(kind).Elem()
+	switch kind.Kind() {
+	case reflect.String:
+		parsed.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.SetFloat(f)
+	default:
+		return parsed, errors.New("unsupported type " + kind.String())
+	}
+	return parsed, nil
+}
+
+// Compares two values of the same type.
+func compareValues(a reflect.Value, b reflect.Value) int {
+	if t, ok := a.Interface().(time.Time); ok {
+		return t.Compare(b.Interface().(time.Time))
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0
+		} else if b.Bool() {
+			return -1
+		}
+		return 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compare(a.Float(), b.Float())
+	}
+	return 0
+}
+
+func compare[N int64 | uint64 | float64](a N, b N) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Encodes the sort values of a model into an opaque cursor.
+func encodeCursor(values []reflect.Value) (string, error) {
+	raw := []interface{}{}
+	for _, value := range values {
+		raw = append(raw, value.Interface())
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Decodes the cursor into values with the types of the sort keys.
+func decodeCursor(cursor string, sort []resolvedSort) ([]reflect.Value, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := []json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if len(raw) != len(sort) {
+		return nil, errors.New("cursor has the wrong amount of values")
+	}
+
+	values := []reflect.Value{}
+	for i, s := range sort {
+		value := reflect.New(s.field.kind)
+		if err := json.Unmarshal(raw[i], value.Interface()); err != nil {
+			return nil, err
+		}
+		values = append(values, value.Elem())
+	}
+	return values, nil
+}
+
+// Escapes the wildcards of a LIKE pattern, using '!' as the escape character.
+func escapeLike(pattern string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(pattern)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+// Returns the names of the items on the page.
+func itemNames(page *Page[Item]) []string {
+	names := []string{}
+	for _, item := range page.Data {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestGetItemsQuery(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday", "Red bricks", "Blue bricks", "Cake")
+
+		query := Query{
+			Limit:   1,
+			Sort:    []Sort{{Field: "name", Descending: true}},
+			Filters: []Filter{{Field: "name", Operator: Contains, Value: "BRICK"}},
+		}
+
+		names := []string{}
+		for {
+			page, err := repos.Wishlists.GetItems(wishlist.Id, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names = append(names, itemNames(page)...)
+			if !page.Pagination.HasMore {
+				break
+			}
+			query.Cursor = page.Pagination.NextCursor
+		}
+
+		if len(names) != 2 || names[0] != "Red bricks" || names[1] != "Blue bricks" {
+			t.Errorf("pages contain %v, want [Red bricks Blue bricks]", names)
+		}
+
+		// Only text can contain other text.
+		query = Query{Filters: []Filter{{Field: "quantity", Operator: Contains, Value: "1"}}}
+		if _, err := repos.Wishlists.GetItems(wishlist.Id, query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("filtering a number on what it contains returned %v, want %v", err, ErrInvalidQuery)
+		}
+
+		query = Query{Filters: []Filter{{Field: "quantity", Operator: GreaterOrEqual, Value: "one"}}}
+		if _, err := repos.Wishlists.GetItems(wishlist.Id, query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("filtering a number on text returned %v, want %v", err, ErrInvalidQuery)
+		}
+	})
+}
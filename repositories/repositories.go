@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// The database the SQL repositories run their queries on. Both *goqu.Database and
+// *goqu.TxDatabase satisfy it, so repositories can take part in a transaction.
+type Database interface {
+	From(from ...interface{}) *goqu.SelectDataset
+	Insert(table interface{}) *goqu.InsertDataset
+	Update(table interface{}) *goqu.UpdateDataset
+	Delete(table interface{}) *goqu.DeleteDataset
+	Dialect() string
+}
+
+// Runs fn in a transaction. If the database already is a transaction, fn becomes part of it,
+// so it is committed or rolled back together with the rest of that transaction.
+func withTx(db Database, fn func(tx *goqu.TxDatabase) error) error {
+	switch db := db.(type) {
+	case *goqu.TxDatabase:
+		return fn(db)
+	case *goqu.Database:
+		return db.WithTx(fn)
+	}
+	return errors.New("transactions are not supported by this database")
+}
+
+// The queries that can be done on items.
+type ItemStore interface {
+	Repository[Item, string]
+	Reorder(wishlistId string, ids []string, after string) error
+}
+
+// The queries that can be done on wishlists and the permissions of their viewers.
+type WishlistStore interface {
+	Repository[Wishlist, string]
+	GetItems(id string, query Query) (*Page[Item], error)
+	GetAccessibleItems(ownership string, query Query) (*Page[Item], error)
+	GetOwnedWishlists(ownership string) ([]Wishlist, error)
+	GetSavedWishlists(ownership string) ([]WishlistPermissioned, error)
+	GetPermission(wishlistId string, ownership string) (string, error)
+	RegisterPermission(wishlistId string, ownership string, password string) error
+	RotatePassword(id string, demote bool) (*Wishlist, error)
+	GetViewers(wishlistId string) ([]WishlistViewer, error)
+	SetPermission(wishlistId string, ownership string, permissions string) error
+	RemoveViewer(wishlistId string, ownership string) error
+	ClaimOwnership(from string, to string) (*Claimed, error)
+	GetDeletedWishlists(ownership string) ([]Wishlist, error)
+	GetDeletedItems(ownership string) ([]Item, error)
+}
+
+// The queries that can be done on the audit log.
+type AuditStore interface {
+	GetHistory(wishlistId string, query Query) (*Page[AuditEntry], error)
+	Undo(wishlistId string, actor string, steps int) ([]AuditEntry, error)
+	Redo(wishlistId string, actor string, steps int) ([]AuditEntry, error)
+}
+
+// The queries that can be done on the search index.
+type SearchStore interface {
+	Search(ownership string, query string, limit int) ([]SearchResult, error)
+}
+
+// The queries that can be done on user accounts, their sessions and their API keys.
+type UserStore interface {
+	AddUser(user User) (*User, error)
+	GetUserById(id string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	AddExternalUser(user User, identity ExternalIdentity) (*User, error)
+	LinkExternalIdentity(identity ExternalIdentity) error
+	GetUserByExternalIdentity(issuer string, subject string) (*User, error)
+	AddLoginAttempt(attempt LoginAttempt) error
+	TakeLoginAttempt(id string, at time.Time) (*LoginAttempt, error)
+	AddSession(session Session) error
+	TakeSession(id string, at time.Time) (*Session, error)
+	DeleteSession(id string) error
+	AddApiKey(key ApiKey) (*ApiKey, error)
+	GetApiKeys(userId string) ([]ApiKey, error)
+	DeleteApiKey(userId string, id string) error
+	UseApiKey(keyHash string, at time.Time) (*ApiKey, error)
+	ExchangeLegacyKey(key string) (string, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// The queries that can be done on the invitations to wishlists.
+type InvitationStore interface {
+	AddInvitation(invitation Invitation) (*Invitation, error)
+	GetInvitations(wishlistId string, at time.Time) ([]Invitation, error)
+	DeleteInvitation(wishlistId string, id string) error
+	RedeemInvitation(wishlistId string, token string, ownership string, at time.Time) (*Invitation, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// The queries that can be done on failed attempts to guess the password of a wishlist.
+type PasswordAttemptStore interface {
+	GetLockout(wishlistId string, clientIp string) (time.Time, error)
+	LockThrottles(wishlistId string, clientIp string) (time.Time, error)
+	AddFailedAttempt(attempt FailedPasswordAttempt) (time.Time, error)
+	ResetFailures(wishlistId string) error
+	GetFailedAttempts(wishlistId string) ([]FailedPasswordAttempt, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// The queries that can be done on the reservations of items.
+type ReservationStore interface {
+	Reserve(reservation Reservation) (*Reservation, error)
+	CancelReservation(itemId string, reserver string) error
+	GetReservations(itemIds []string) ([]Reservation, error)
+}
+
+// The queries that can be done on the pledges to items.
+type PledgeStore interface {
+	Pledge(pledge Pledge) (*Pledge, error)
+	WithdrawPledge(itemId string, contributor string) error
+	GetPledges(itemIds []string) ([]Pledge, error)
+}
+
+// The set of repositories the API reads from and writes to.
+type Repositories struct {
+	Items            ItemStore
+	Wishlists        WishlistStore
+	Audit            AuditStore
+	Search           SearchStore
+	Users            UserStore
+	Invitations      InvitationStore
+	PasswordAttempts PasswordAttemptStore
+	Reservations     ReservationStore
+	Pledges          PledgeStore
+	// Runs fn with repositories that are part of a single transaction.
+	transaction func(fn func(repos *Repositories) error) error
+}
+
+// Runs fn as a single unit of work. Every change made through the repositories that are passed
+// to fn is committed when fn returns nil, and rolled back when fn returns an error.
+//
+// Transactions started within fn are part of the same unit of work.
+func (repos *Repositories) Transaction(fn func(repos *Repositories) error) error {
+	return repos.transaction(fn)
+}
+
+// Creates repositories that are backed by the given database. When the database is a transaction,
+// transactions on the repositories become part of it.
+func NewSQLRepositories(db Database) *Repositories {
+	return newSQLRepositories(db, NewSearchRepository(db))
+}
+
+// Creates repositories that are backed by the given database, with a search repository that was
+// created before, so transactions do not have to check the kind of search index again.
+func newSQLRepositories(db Database, search *SearchRepository) *Repositories {
+	repos := &Repositories{
+		Items:            NewItemRepository(db),
+		Wishlists:        NewWishlistRepository(db),
+		Audit:            NewAuditRepository(db),
+		Search:           search,
+		Users:            NewUserRepository(db),
+		Invitations:      NewInvitationRepository(db),
+		PasswordAttempts: NewPasswordAttemptRepository(db),
+		Reservations:     NewReservationRepository(db),
+		Pledges:          NewPledgeRepository(db),
+	}
+	repos.transaction = func(fn func(repos *Repositories) error) error {
+		return withTx(db, func(tx *goqu.TxDatabase) error {
+			return fn(newSQLRepositories(tx, &SearchRepository{db: tx, fullText: search.fullText}))
+		})
+	}
+	return repos
+}
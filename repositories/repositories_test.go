@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"wishlist-backend/migrations"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Opens a new SQLite database in a temporary directory, without applying any migrations.
+func openSQLite(t *testing.T) *goqu.Database {
+	t.Helper()
+
+	// Concurrent transactions wait for each other instead of failing right away.
+	conn, err := sql.Open(SQLiteDriver, "file:"+filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on&_busy_timeout=10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return goqu.New("sqlite3", conn)
+}
+
+// Opens a new SQLite database with every migration applied. The search index is a full-text
+// index when the driver was built with FTS5.
+func newSQLiteDatabase(t *testing.T) *goqu.Database {
+	t.Helper()
+
+	db := openSQLite(t)
+	var fts5 bool
+	if _, err := db.Select(goqu.Func("sqlite_compileoption_used", "ENABLE_FTS5")).ScanVal(&fts5); err != nil {
+		t.Fatal(err)
+	}
+
+	fallbacks := []string{}
+	if !fts5 {
+		fallbacks = append(fallbacks, migrations.NoFTS5)
+	}
+
+	if err := migrations.Latest(db, fallbacks...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Runs the test on repositories that are kept in memory, and on repositories that are backed by a
+// new SQLite database.
+func testRepositories(t *testing.T, test func(t *testing.T, repos *Repositories)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryRepositories())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, NewSQLRepositories(newSQLiteDatabase(t)))
+	})
+}
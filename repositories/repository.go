@@ -1,131 +1,307 @@
 package repository
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 )
 
+// Returned when a model is updated while it was already changed by someone else.
+var ErrVersionConflict = errors.New("the model was changed since it was last retrieved")
+
 type Model[I any] struct {
-	Id I `json:"id" db:"Id" goqu:"skipinsert"`
+	Id        I         `json:"id" db:"Id" goqu:"skipupdate"`
+	CreatedAt time.Time `json:"createdAt" db:"CreatedAt" goqu:"skipupdate"`
+	// Incremented on every update, used to detect concurrent changes.
+	Version int `json:"version" db:"Version" goqu:"skipupdate"`
+	// The moment the model was moved to the trash, nil if the model is not in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" db:"DeletedAt" goqu:"skipinsert,skipupdate"`
 }
 
+// A repository of models that can be moved to the trash. Models in the trash are hidden
+// from every method, except for the methods that explicitly work on the trash.
+//
+// Every change is recorded in the audit log, together with the actor that made the change.
 type Repository[T interface{}, I any] interface {
-	Update(o T, id *I) error
-	Add(o T) (*T, error)
+	// Replaces the model and returns the result. If the version of the model is not 0, the update
+	// only succeeds if the stored model has the same version, otherwise ErrVersionConflict is returned.
+	Update(o T, id *I, actor string) (*T, error)
+	Add(o T, actor string) (*T, error)
 	GetById(id I) (*T, error)
-	GetAll() (*[]T, error)
-	DeleteById(id T) error
+	// Returns a single page of models, as described by the query.
+	GetAll(query Query) (*Page[T], error)
+	// Moves the model to the trash.
+	DeleteById(id I, actor string) error
+	// Searches the trash for the model with the given ID.
+	GetDeletedById(id I) (*T, error)
+	// Moves the model out of the trash.
+	Restore(id I, actor string) error
+	// Permanently removes the models that were moved to the trash before the given moment.
+	// Returns the amount of removed models.
+	Purge(before time.Time) (int64, error)
 	RemoveId(*T)
 }
 
 // An abstract repository that partially implements the Repository[T, I] interface.
 //
-// The methods `Add(o T) (*T, error)` and `Update(o T, id *I) error` do not have a proper implementation
-// in this abstract repository. They have method placeholders that will return errors when invoked.
+// The repository only makes use of SQL that is supported by all of the dialects in this
+// package, so it works on both SQLite and PostgreSQL.
 //
 // Generic[T] is the type of the model of this controller.
 //
 // Generic[I] is the type of the model's ID of this controller. The ID of your model
 // should always be named 'Id' in the database for it to work with this controller and its
 // repository.
-type AbstractSQLiteRepository[T interface{}, I any] struct {
+type AbstractSQLRepository[T interface{}, I any] struct {
 	Repository[T, I]
-	db     *goqu.Database
+	db     Database
 	empty  T
 	dbName string
+	// Generates the ID of newly added models.
+	newId func() I
+}
+
+// Generates a random ID of 32 hexadecimal characters.
+func NewId() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		panic("something went wrong while generating an id")
+	}
+	return hex.EncodeToString(bytes)
 }
 
 // Searches its database for the model with the given ID.
-func (repo *AbstractSQLiteRepository[T, I]) GetById(id I) (*T, error) {
-	template := repo.empty
-	found, err := repo.db.From(repo.dbName).Where(goqu.Ex{
-		"Id": id,
-	}).ScanStruct(&template)
+func (repo *AbstractSQLRepository[T, I]) GetById(id I) (*T, error) {
+	return repo.getById(id, goqu.C("DeletedAt").IsNull())
+}
 
-	if !found {
-		return nil, os.ErrNotExist
+// Returns a page of values in its model's database table.
+func (repo *AbstractSQLRepository[T, I]) GetAll(query Query) (*Page[T], error) {
+	return paginate[T](repo.db.From(repo.dbName).Where(goqu.C("DeletedAt").IsNull()), query)
+}
+
+// Updates a given model's value. If an ID is provided as the second value, it should take precedent over any IDs in the model.
+//
+// Fields tagged with `goqu:"skipupdate"` keep their stored value. The version of the model is
+// incremented, and has to match the stored version unless it is 0.
+func (repo *AbstractSQLRepository[T, I]) Update(o T, id *I, actor string) (*T, error) {
+	searchId := getId[T, I](o)
+	if id != nil {
+		searchId = *id
 	}
 
+	record, err := exp.NewRecordFromStruct(o, false, true)
 	if err != nil {
 		return nil, err
 	}
+	record["Version"] = goqu.L("? + 1", goqu.C("Version"))
 
-	return &template, nil
-}
+	after := repo.empty
+	err = withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		before := repo.empty
+		found, err := tx.From(repo.dbName).Where(
+			goqu.C("Id").Eq(searchId),
+			goqu.C("DeletedAt").IsNull(),
+		).ScanStruct(&before)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		if version := getVersion(o); version != 0 && version != getVersion(before) {
+			return ErrVersionConflict
+		}
 
-// Returns all values in its model's database table.
-func (repo *AbstractSQLiteRepository[T, I]) GetAll() (*[]T, error) {
-	models := []T{}
-	err := repo.db.From(repo.dbName).ScanStructs(&models)
+		// The stored version is checked again, in case the model changed after it was read.
+		found, err = tx.Update(repo.dbName).Set(record).Where(
+			goqu.C("Id").Eq(searchId),
+			goqu.C("Version").Eq(getVersion(before)),
+		).Returning(goqu.Star()).Executor().ScanStruct(&after)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return ErrVersionConflict
+		}
+
+		return recordChange(tx, actor, AuditUpdate, repo.dbName, &before, &after)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &models, nil
+	return &after, nil
 }
 
-// Updates a given model's value. If an ID is provided as the second value, it should take precedent over any IDs in the model.
-func (repo *AbstractSQLiteRepository[T, I]) Update(o T, id *I) error {
-	_, err := repo.db.Update(repo.dbName).Set(o).
-		Where(goqu.C("Id").Eq(id)).
-		Executor().Exec()
+// Adds a value to the model's database table. IDs will be auto generated, provided IDs should be ignored.
+func (repo *AbstractSQLRepository[T, I]) Add(o T, actor string) (*T, error) {
+	setId(&o, repo.newId())
+	setCreatedAt(&o, time.Now().UTC())
+	setVersion(&o, 1)
 
-	return err
-}
+	template := repo.empty
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		_, err := tx.Insert(repo.dbName).Rows(o).
+			Returning(goqu.Star()).
+			Executor().ScanStruct(&template)
 
-// Adds a value to the model's database table. IDs will be auto generated, provided IDs should be ignored.
-func (repo *AbstractSQLiteRepository[T, I]) Add(o T) (*T, error) {
-	tx, err := repo.db.Begin()
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return err
+		}
 
-	result, err := tx.Insert(repo.dbName).Rows(o).Executor().Exec()
+		return recordChange(tx, actor, AuditAdd, repo.dbName, nil, &template)
+	})
 
 	if err != nil {
-		if rErr := tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
 		return nil, err
 	}
 
-	rowId, err := result.LastInsertId()
+	return &template, nil
+}
+
+// Moves the model with the given ID to the trash by setting its DeletedAt column.
+func (repo *AbstractSQLRepository[T, I]) DeleteById(id I, actor string) error {
+	return repo.setDeletedAt(id, actor, time.Now().UTC())
+}
+
+// Searches its database for the model with the given ID, if that model is in the trash.
+func (repo *AbstractSQLRepository[T, I]) GetDeletedById(id I) (*T, error) {
+	return repo.getById(id, goqu.C("DeletedAt").IsNotNull())
+}
+
+// Moves the model with the given ID out of the trash.
+func (repo *AbstractSQLRepository[T, I]) Restore(id I, actor string) error {
+	return repo.setDeletedAt(id, actor, nil)
+}
+
+// Permanently deletes the rows that were moved to the trash before the given moment.
+func (repo *AbstractSQLRepository[T, I]) Purge(before time.Time) (int64, error) {
+	result, err := repo.db.Delete(repo.dbName).
+		Where(goqu.C("DeletedAt").Lt(before.UTC())).
+		Executor().Exec()
 
 	if err != nil {
-		if rErr := tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
-		return nil, err
+		return 0, err
 	}
 
+	return result.RowsAffected()
+}
+
+func (repo *AbstractSQLRepository[T, I]) getById(id I, condition goqu.Expression) (*T, error) {
 	template := repo.empty
-	_, err = tx.From(repo.dbName).
-		Where(goqu.C("rowid").Eq(rowId)).
-		ScanStruct(&template)
+	found, err := repo.db.From(repo.dbName).Where(goqu.Ex{
+		"Id": id,
+	}, condition).ScanStruct(&template)
 
 	if err != nil {
-		if rErr := tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
 		return nil, err
 	}
 
-	tx.Commit()
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
 	return &template, nil
 }
 
-func (repo *AbstractSQLiteRepository[T, I]) DeleteById(id T) error {
-	result, err := repo.db.Delete(repo.dbName).Where(goqu.C("Id").Eq(id)).Executor().Exec()
-
-	if err != nil {
-		return err
+// Moves the model with the given ID in or out of the trash, depending on whether deletedAt is nil.
+//
+// Returns os.ErrNotExist if no such model exists, or if the model is already where it is moved to.
+func (repo *AbstractSQLRepository[T, I]) setDeletedAt(id I, actor string, deletedAt interface{}) error {
+	condition := goqu.C("DeletedAt").IsNull()
+	if deletedAt == nil {
+		condition = goqu.C("DeletedAt").IsNotNull()
 	}
 
-	if count, err := result.RowsAffected(); count <= 0 && err != nil {
-		return os.ErrNotExist
-	}
+	return withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		after := repo.empty
+		found, err := tx.Update(repo.dbName).
+			Set(goqu.Record{"DeletedAt": deletedAt}).
+			Where(goqu.C("Id").Eq(id), condition).
+			Returning(goqu.Star()).
+			Executor().ScanStruct(&after)
 
-	return nil
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		if deletedAt == nil {
+			return recordChange(tx, actor, AuditRestore, repo.dbName, nil, &after)
+		}
+
+		// The model is recorded as it was before it was moved to the trash.
+		setDeletedAt(&after, nil)
+		return recordChange(tx, actor, AuditDelete, repo.dbName, &after, nil)
+	})
+}
+
+// Returns the value of the 'Id' field of the model.
+func getId[T interface{}, I any](o T) I {
+	return reflect.ValueOf(o).FieldByName("Id").Interface().(I)
+}
+
+// Sets the value of the 'Id' field of the model.
+func setId[T interface{}, I any](o *T, id I) {
+	reflect.ValueOf(o).Elem().FieldByName("Id").Set(reflect.ValueOf(id))
+}
+
+// Returns the value of the 'DeletedAt' field of the model.
+func getDeletedAt[T interface{}](o T) *time.Time {
+	return reflect.ValueOf(o).FieldByName("DeletedAt").Interface().(*time.Time)
+}
+
+// Sets the value of the 'DeletedAt' field of the model.
+func setDeletedAt[T interface{}](o *T, deletedAt *time.Time) {
+	reflect.ValueOf(o).Elem().FieldByName("DeletedAt").Set(reflect.ValueOf(deletedAt))
+}
+
+// Sets the value of the 'CreatedAt' field of the model.
+func setCreatedAt[T interface{}](o *T, createdAt time.Time) {
+	reflect.ValueOf(o).Elem().FieldByName("CreatedAt").Set(reflect.ValueOf(createdAt))
+}
+
+// Returns the value of the 'Version' field of the model.
+func getVersion[T interface{}](o T) int {
+	return reflect.ValueOf(o).FieldByName("Version").Interface().(int)
+}
+
+// Sets the value of the 'Version' field of the model.
+func setVersion[T interface{}](o *T, version int) {
+	reflect.ValueOf(o).Elem().FieldByName("Version").Set(reflect.ValueOf(version))
+}
+
+// Copies the fields tagged with `goqu:"skipupdate"` from the stored model to the updated model,
+// so repositories that are not backed by SQL update the same fields as the SQL repositories.
+func keepSkippedFields[T interface{}](stored T, updated *T) {
+	storedValue := reflect.ValueOf(stored)
+	updatedValue := reflect.ValueOf(updated).Elem()
+
+	for _, field := range reflect.VisibleFields(storedValue.Type()) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+
+		if slices.Contains(strings.Split(field.Tag.Get("goqu"), ","), "skipupdate") {
+			updatedValue.FieldByIndex(field.Index).Set(storedValue.FieldByIndex(field.Index))
+		}
+	}
 }
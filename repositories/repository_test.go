@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUpdates(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+
+		// Everyone updates the version they retrieved, so only the first update succeeds.
+		var wait sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				update := *wishlist
+				update.Name = fmt.Sprint("Birthday ", i)
+				_, errs[i] = repos.Wishlists.Update(update, &wishlist.Id, "owner")
+			}()
+		}
+		wait.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, ErrVersionConflict) {
+				t.Error(err)
+			}
+		}
+
+		stored, err := repos.Wishlists.GetById(wishlist.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if succeeded != 1 || stored.Version != wishlist.Version+1 {
+			t.Errorf("%d updates succeeded and the version is %d, want 1 update and version %d", succeeded, stored.Version, wishlist.Version+1)
+		}
+	})
+}
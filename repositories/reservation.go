@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Returned when a reservation would reserve more units of an item than are wanted.
+var ErrOverReserved = errors.New("not enough units of the item are left to reserve")
+
+// A promise of a guest to buy one or more units of an item. Reservations are kept from the owner
+// of the wishlist, so their gifts stay a surprise.
+type Reservation struct {
+	ItemId string `json:"-" db:"ItemId"`
+	// The identity of the guest that reserved the item.
+	Reserver  string    `json:"-" db:"Reserver"`
+	Quantity  int       `json:"quantity" db:"Quantity"`
+	Note      string    `json:"note" db:"Note"`
+	CreatedAt time.Time `json:"reservedAt" db:"CreatedAt"`
+}
+
+type ReservationRepository struct {
+	db Database
+}
+
+func NewReservationRepository(db Database) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+// Reserves units of the item for the reserver, replacing the reservation they already had.
+// Returns ErrOverReserved if the other reservations leave too few units, and os.ErrNotExist
+// if the item does not exist or is in the trash.
+func (repo *ReservationRepository) Reserve(reservation Reservation) (*Reservation, error) {
+	reservation.CreatedAt = time.Now().UTC()
+
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		// Writing to the item first locks it, so concurrent reservations of the same item wait for
+		// each other and can not reserve the last unit twice.
+		result, err := tx.Update("Item").
+			Set(goqu.Record{"Quantity": goqu.C("Quantity")}).
+			Where(goqu.C("Id").Eq(reservation.ItemId), goqu.C("DeletedAt").IsNull()).
+			Executor().Exec()
+
+		if err := checkAffected(result, err); err != nil {
+			return err
+		}
+
+		var wanted int
+		if _, err := tx.From("Item").Select("Quantity").Where(goqu.C("Id").Eq(reservation.ItemId)).ScanVal(&wanted); err != nil {
+			return err
+		}
+
+		others := []Reservation{}
+		err = tx.From("Reservation").Where(
+			goqu.C("ItemId").Eq(reservation.ItemId),
+			goqu.C("Reserver").Neq(reservation.Reserver),
+		).ScanStructs(&others)
+
+		if err != nil {
+			return err
+		}
+
+		if reserved(others)+reservation.Quantity > wanted {
+			return ErrOverReserved
+		}
+
+		_, err = tx.Delete("Reservation").Where(
+			goqu.C("ItemId").Eq(reservation.ItemId),
+			goqu.C("Reserver").Eq(reservation.Reserver),
+		).Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Insert("Reservation").Rows(reservation).Executor().Exec()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// Cancels the reservation of the reserver. Returns os.ErrNotExist if they did not reserve the item.
+func (repo *ReservationRepository) CancelReservation(itemId string, reserver string) error {
+	result, err := repo.db.Delete("Reservation").
+		Where(goqu.C("ItemId").Eq(itemId), goqu.C("Reserver").Eq(reserver)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Returns the reservations of the items, oldest first.
+func (repo *ReservationRepository) GetReservations(itemIds []string) ([]Reservation, error) {
+	reservations := []Reservation{}
+	if len(itemIds) <= 0 {
+		return reservations, nil
+	}
+
+	err := repo.db.From("Reservation").
+		Where(goqu.C("ItemId").In(itemIds)).
+		Order(goqu.C("CreatedAt").Asc()).
+		ScanStructs(&reservations)
+
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// Moves the reservations of one identity to another. When both reserved the same item, their
+// reservations are combined, up to what the other reservations leave of the item.
+func claimReservations(tx *goqu.TxDatabase, from string, to string) error {
+	reservations := []Reservation{}
+	if err := tx.From("Reservation").Where(goqu.C("Reserver").Eq(from)).ScanStructs(&reservations); err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		claimed := goqu.Ex{"ItemId": reservation.ItemId, "Reserver": to}
+		target := goqu.Ex{"ItemId": reservation.ItemId, "Reserver": from}
+
+		kept := Reservation{}
+		found, err := tx.From("Reservation").Where(claimed).ScanStruct(&kept)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			_, err = tx.Update("Reservation").Set(goqu.Record{"Reserver": to}).Where(target).Executor().Exec()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Writing to the item first locks it, like reserving it does.
+		_, err = tx.Update("Item").
+			Set(goqu.Record{"Quantity": goqu.C("Quantity")}).
+			Where(goqu.C("Id").Eq(reservation.ItemId)).
+			Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		var wanted int
+		if _, err := tx.From("Item").Select("Quantity").Where(goqu.C("Id").Eq(reservation.ItemId)).ScanVal(&wanted); err != nil {
+			return err
+		}
+
+		others := []Reservation{}
+		err = tx.From("Reservation").Where(
+			goqu.C("ItemId").Eq(reservation.ItemId),
+			goqu.C("Reserver").NotIn(from, to),
+		).ScanStructs(&others)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Update("Reservation").
+			Set(goqu.Record{"Quantity": combinedQuantity(kept, reservation, wanted, others)}).
+			Where(claimed).
+			Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Delete("Reservation").Where(target).Executor().Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the quantity of two reservations of the same item that are combined. It is capped at
+// what the other reservations leave of the units the owner wants, but the reservation that is
+// kept never shrinks.
+func combinedQuantity(kept Reservation, claimed Reservation, wanted int, others []Reservation) int {
+	return max(kept.Quantity, min(kept.Quantity+claimed.Quantity, wanted-reserved(others)))
+}
+
+// Returns the amount of units the reservations reserve together.
+func reserved(reservations []Reservation) int {
+	total := 0
+	for _, reservation := range reservations {
+		total += reservation.Quantity
+	}
+	return total
+}
+
+// Keeps reservations in memory.
+type MemoryReservationRepository struct {
+	mutex        *sync.RWMutex
+	reservations []Reservation
+	items        *MemoryItemRepository
+}
+
+func NewMemoryReservationRepository(items *MemoryItemRepository) *MemoryReservationRepository {
+	return &MemoryReservationRepository{
+		mutex:        &sync.RWMutex{},
+		reservations: []Reservation{},
+		items:        items,
+	}
+}
+
+// Reserves units of the item for the reserver, replacing the reservation they already had.
+// Returns ErrOverReserved if the other reservations leave too few units, and os.ErrNotExist
+// if the item does not exist or is in the trash.
+func (repo *MemoryReservationRepository) Reserve(reservation Reservation) (*Reservation, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, err := repo.items.GetById(reservation.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	others := slices.DeleteFunc(slices.Clone(repo.reservations), func(stored Reservation) bool {
+		return stored.ItemId != reservation.ItemId || stored.Reserver == reservation.Reserver
+	})
+
+	if reserved(others)+reservation.Quantity > item.Quantity {
+		return nil, ErrOverReserved
+	}
+
+	reservation.CreatedAt = time.Now().UTC()
+	repo.reservations = slices.DeleteFunc(repo.reservations, func(stored Reservation) bool {
+		return stored.ItemId == reservation.ItemId && stored.Reserver == reservation.Reserver
+	})
+	repo.reservations = append(repo.reservations, reservation)
+
+	return &reservation, nil
+}
+
+// Cancels the reservation of the reserver. Returns os.ErrNotExist if they did not reserve the item.
+func (repo *MemoryReservationRepository) CancelReservation(itemId string, reserver string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	count := len(repo.reservations)
+	repo.reservations = slices.DeleteFunc(repo.reservations, func(reservation Reservation) bool {
+		return reservation.ItemId == itemId && reservation.Reserver == reserver
+	})
+
+	if len(repo.reservations) == count {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Returns the reservations of the items, oldest first.
+func (repo *MemoryReservationRepository) GetReservations(itemIds []string) ([]Reservation, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	reservations := []Reservation{}
+	for _, reservation := range repo.reservations {
+		if slices.Contains(itemIds, reservation.ItemId) {
+			reservations = append(reservations, reservation)
+		}
+	}
+	return reservations, nil
+}
+
+// Moves the reservations of one identity to another. When both reserved the same item, their
+// reservations are combined, up to what the other reservations leave of the item.
+func (repo *MemoryReservationRepository) claim(from string, to string) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i, reservation := range repo.reservations {
+		if reservation.Reserver != from {
+			continue
+		}
+
+		index := slices.IndexFunc(repo.reservations, func(stored Reservation) bool {
+			return stored.ItemId == reservation.ItemId && stored.Reserver == to
+		})
+
+		if index < 0 {
+			repo.reservations[i].Reserver = to
+			continue
+		}
+
+		// Items in the trash keep their reservations, so they can be combined as well.
+		item, err := repo.items.getById(reservation.ItemId, false)
+		if err != nil {
+			item, err = repo.items.getById(reservation.ItemId, true)
+		}
+
+		if err == nil {
+			others := slices.DeleteFunc(slices.Clone(repo.reservations), func(stored Reservation) bool {
+				return stored.ItemId != reservation.ItemId || stored.Reserver == from || stored.Reserver == to
+			})
+			repo.reservations[index].Quantity = combinedQuantity(repo.reservations[index], reservation, item.Quantity, others)
+		}
+		repo.reservations[i].Quantity = 0
+	}
+
+	repo.reservations = slices.DeleteFunc(repo.reservations, func(reservation Reservation) bool {
+		return reservation.Quantity <= 0
+	})
+}
+
+// Copies the stored reservations, and returns a function that restores them to the copy.
+func (repo *MemoryReservationRepository) snapshot() (restore func()) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	reservations := slices.Clone(repo.reservations)
+
+	return func() {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+
+		repo.reservations = reservations
+	}
+}
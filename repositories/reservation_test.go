@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Adds an item to the wishlist that the owner wants the given amount of.
+func addItem(t *testing.T, repos *Repositories, wishlist *Wishlist, item Item) *Item {
+	t.Helper()
+
+	item.WishlistId = wishlist.Id
+	added, err := repos.Items.Add(item, wishlist.Ownership)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return added
+}
+
+func TestConcurrentReservations(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+		item := addItem(t, repos, wishlist, Item{Name: "Bricks", Quantity: 3})
+
+		var wait sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				_, errs[i] = repos.Reservations.Reserve(Reservation{ItemId: item.Id, Reserver: fmt.Sprint("guest ", i), Quantity: 1})
+			}()
+		}
+		wait.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, ErrOverReserved) {
+				t.Error(err)
+			}
+		}
+
+		reservations, err := repos.Reservations.GetReservations([]string{item.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if succeeded != 3 || reserved(reservations) != 3 {
+			t.Errorf("%d reservations succeeded and %d units are reserved, want 3 of both", succeeded, reserved(reservations))
+		}
+	})
+}
+
+func TestClaimReservations(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, _ := addWishlist(t, repos, "owner", "Birthday")
+		item := addItem(t, repos, wishlist, Item{Name: "Bricks", Quantity: 3})
+
+		for _, reserver := range []string{"anonymous", "account", "guest"} {
+			if _, err := repos.Reservations.Reserve(Reservation{ItemId: item.Id, Reserver: reserver, Quantity: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The owner wants fewer units than were reserved since.
+		item.Quantity = 2
+		if _, err := repos.Items.Update(*item, &item.Id, "owner"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Wishlists.ClaimOwnership("anonymous", "account"); err != nil {
+			t.Fatal(err)
+		}
+
+		reservations, err := repos.Reservations.GetReservations([]string{item.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		quantities := map[string]int{}
+		for _, reservation := range reservations {
+			quantities[reservation.Reserver] = reservation.Quantity
+		}
+
+		// The reservation of the account is not combined beyond what the guest leaves of the item.
+		if len(quantities) != 2 || quantities["account"] != 1 || quantities["guest"] != 1 {
+			t.Errorf("reservations = %v, want 1 unit for both the account and the guest", quantities)
+		}
+	})
+}
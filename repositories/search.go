@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// The amount of search results that is returned when no limit is given.
+const DefaultSearchLimit = 20
+
+// Surround the matches in the snippets that are returned by the database. They are replaced
+// by HTML tags after the rest of the snippet is escaped.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// A wishlist or item that matches a search query.
+type SearchResult struct {
+	// The table of the model that matched, either Wishlist or Item.
+	Entity     string `json:"entity" db:"Entity"`
+	Id         string `json:"id" db:"EntityId"`
+	WishlistId string `json:"wishlistId" db:"WishlistId"`
+	Name       string `json:"name" db:"Name"`
+	// An HTML fragment of the text that matched, the matching words are wrapped in <mark> tags.
+	Snippet string `json:"snippet" db:"Snippet"`
+	// How well the model matches the query, higher is better. Ranks can only be compared
+	// within the same search.
+	Rank float64 `json:"rank" db:"Rank"`
+}
+
+type SearchRepository struct {
+	db Database
+	// Whether the search index is a full-text index. On SQLite, it is a regular table that is scanned
+	// when the database was migrated without FTS5.
+	fullText bool
+}
+
+// Creates the repository for a database that has been migrated, as the kind of search index it
+// has is only checked once.
+func NewSearchRepository(db Database) *SearchRepository {
+	return &SearchRepository{db: db, fullText: hasFullText(db)}
+}
+
+// Searches the names and descriptions of the wishlists and items that the owner can see, and the
+// hosts of the URLs of those items. Every word of the query has to match the start of a word.
+// Returns the best matches first.
+//
+// On SQLite, the search index is an FTS5 table, which is only available when the driver is built
+// with the sqlite_fts5 build tag. Without it, the index is a regular table that is scanned instead.
+func (repo *SearchRepository) Search(ownership string, query string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	results := []SearchResult{}
+	if len(terms) <= 0 {
+		return results, nil
+	}
+
+	if !repo.fullText {
+		return repo.scan(ownership, terms, limit)
+	}
+
+	var match, rank, snippet exp.LiteralExpression
+	if repo.db.Dialect() == "postgres" {
+		prefixes := make([]string, len(terms))
+		for i, term := range terms {
+			prefixes[i] = term + ":*"
+		}
+
+		tsquery := goqu.L("to_tsquery('simple', ?)", strings.Join(prefixes, " & "))
+		match = goqu.L(`"SearchIndex"."Document" @@ ?`, tsquery)
+		rank = goqu.L(`ts_rank("SearchIndex"."Document", ?)`, tsquery)
+		snippet = goqu.L(
+			`ts_headline('simple', concat_ws(' ', "SearchIndex"."Name", "SearchIndex"."Description", "SearchIndex"."Host"), ?, ?)`,
+			tsquery, "StartSel="+matchStart+", StopSel="+matchEnd+", MinWords=5, MaxWords=15",
+		)
+	} else {
+		prefixes := make([]string, len(terms))
+		for i, term := range terms {
+			prefixes[i] = `"` + term + `"*`
+		}
+
+		match = goqu.L(`"SearchIndex" MATCH ?`, strings.Join(prefixes, " "))
+		// BM25 scores are negative, lower is better. The weights are those of the columns of the index.
+		rank = goqu.L(`-bm25("SearchIndex", 0, 0, 10.0, 5.0, 1.0)`)
+		snippet = goqu.L(`snippet("SearchIndex", -1, ?, ?, '…', 12)`, matchStart, matchEnd)
+	}
+
+	err := repo.visible(ownership).
+		Select(
+			goqu.I("SearchIndex.Entity").As("Entity"),
+			goqu.I("SearchIndex.EntityId").As("EntityId"),
+			goqu.I("Wishlist.Id").As("WishlistId"),
+			goqu.COALESCE(goqu.I("Item.Name"), goqu.I("Wishlist.Name")).As("Name"),
+			snippet.As("Snippet"),
+			rank.As("Rank"),
+		).
+		Where(match).
+		Order(goqu.I("Rank").Desc()).
+		Limit(uint(searchLimit(limit))).
+		ScanStructs(&results)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Snippet = highlight(results[i].Snippet)
+	}
+
+	return results, nil
+}
+
+// Returns the entries of the search index whose wishlist or item the owner can see.
+func (repo *SearchRepository) visible(ownership string) *goqu.SelectDataset {
+	viewable := repo.db.From("WishlistViewer").Select("WishlistId").Where(goqu.C("Ownership").Eq(ownership))
+
+	return repo.db.From("SearchIndex").
+		LeftJoin(goqu.T("Item"), goqu.On(
+			goqu.I("SearchIndex.Entity").Eq("Item"),
+			goqu.I("Item.Id").Eq(goqu.I("SearchIndex.EntityId")),
+		)).
+		InnerJoin(goqu.T("Wishlist"), goqu.On(goqu.I("Wishlist.Id").Eq(
+			goqu.Case().
+				When(goqu.I("SearchIndex.Entity").Eq("Item"), goqu.I("Item.WishlistId")).
+				Else(goqu.I("SearchIndex.EntityId")),
+		))).
+		Where(
+			goqu.I("Wishlist.DeletedAt").IsNull(),
+			goqu.Or(goqu.I("SearchIndex.Entity").Eq("Wishlist"), goqu.I("Item.DeletedAt").IsNull()),
+			goqu.Or(goqu.I("Wishlist.Ownership").Eq(ownership), goqu.I("Wishlist.Id").In(viewable)),
+		)
+}
+
+// Returns whether the search index of the database is a full-text index. On SQLite, it is an FTS5
+// table, or a regular table when the database was migrated without FTS5. Scanning works on either
+// kind of table, so it is used when the check fails.
+func hasFullText(db Database) bool {
+	if db.Dialect() == "postgres" {
+		return true
+	}
+
+	var count int
+	_, err := db.From("sqlite_master").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("name").Eq("SearchIndex"), goqu.C("sql").ILike("%USING fts5%")).
+		ScanVal(&count)
+
+	return err == nil && count > 0
+}
+
+// An entry of the search index, with the name of the wishlist or item it belongs to.
+type searchEntry struct {
+	SearchResult
+	IndexedName string `db:"IndexedName"`
+	Description string `db:"Description"`
+	Host        string `db:"Host"`
+}
+
+// Searches the regular table that is the search index on SQLite without FTS5. The entries that
+// contain every term are matched and ranked like the memory repository does.
+func (repo *SearchRepository) scan(ownership string, terms []string, limit int) ([]SearchResult, error) {
+	text := goqu.L(`LOWER("SearchIndex"."Name" || ' ' || "SearchIndex"."Description" || ' ' || "SearchIndex"."Host")`)
+	contains := make([]exp.Expression, len(terms))
+	for i, term := range terms {
+		contains[i] = goqu.L("? LIKE ? ESCAPE '!'", text, "%"+escapeLike(term)+"%")
+	}
+
+	entries := []searchEntry{}
+	err := repo.visible(ownership).
+		Select(
+			goqu.I("SearchIndex.Entity").As("Entity"),
+			goqu.I("SearchIndex.EntityId").As("EntityId"),
+			goqu.I("Wishlist.Id").As("WishlistId"),
+			goqu.COALESCE(goqu.I("Item.Name"), goqu.I("Wishlist.Name")).As("Name"),
+			goqu.I("SearchIndex.Name").As("IndexedName"),
+			goqu.I("SearchIndex.Description").As("Description"),
+			goqu.I("SearchIndex.Host").As("Host"),
+		).
+		Where(contains...).
+		ScanStructs(&entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for _, entry := range entries {
+		if result, ok := matchFields(terms, []string{entry.IndexedName, entry.Description, entry.Host}, []float64{10, 5, 1}); ok {
+			entry.Rank = result.Rank
+			entry.Snippet = result.Snippet
+			results = append(results, entry.SearchResult)
+		}
+	}
+
+	sortByRank(results)
+	return results[:min(len(results), searchLimit(limit))], nil
+}
+
+// Searches the wishlists and items that are kept in memory, like the SQL repository does.
+type MemorySearchRepository struct {
+	wishlists *MemoryWishlistRepository
+}
+
+func NewMemorySearchRepository(wishlists *MemoryWishlistRepository) *MemorySearchRepository {
+	return &MemorySearchRepository{wishlists: wishlists}
+}
+
+// Searches the names and descriptions of the wishlists and items that the owner can see, and the
+// hosts of the URLs of those items. Every word of the query has to match the start of a word.
+// Returns the best matches first.
+func (repo *MemorySearchRepository) Search(ownership string, query string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	results := []SearchResult{}
+	if len(terms) <= 0 {
+		return results, nil
+	}
+
+	saved, err := repo.wishlists.GetSavedWishlists(ownership)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[string]bool{}
+	for _, wishlist := range saved {
+		visible[wishlist.Id] = true
+	}
+
+	wishlists := repo.wishlists.filter(func(wishlist Wishlist) bool {
+		return wishlist.DeletedAt == nil && (wishlist.Ownership == ownership || visible[wishlist.Id])
+	})
+
+	for _, wishlist := range wishlists {
+		visible[wishlist.Id] = true
+		if result, ok := matchFields(terms, []string{wishlist.Name}, []float64{10}); ok {
+			result.Entity = "Wishlist"
+			result.Id = wishlist.Id
+			result.WishlistId = wishlist.Id
+			result.Name = wishlist.Name
+			results = append(results, *result)
+		}
+	}
+
+	items := repo.wishlists.items.filter(func(item Item) bool {
+		return item.DeletedAt == nil && visible[item.WishlistId]
+	})
+
+	for _, item := range items {
+		host := ""
+		if parsed, err := url.Parse(item.Url); err == nil {
+			host = parsed.Host
+		}
+
+		if result, ok := matchFields(terms, []string{item.Name, item.Description, host}, []float64{10, 5, 1}); ok {
+			result.Entity = "Item"
+			result.Id = item.Id
+			result.WishlistId = item.WishlistId
+			result.Name = item.Name
+			results = append(results, *result)
+		}
+	}
+
+	sortByRank(results)
+	return results[:min(len(results), searchLimit(limit))], nil
+}
+
+// Matches the terms against the words of the fields. Every term has to match the start of a
+// word in at least one of the fields. The rank is the sum of the weights of the fields of every match,
+// the snippet is the field with the most matches.
+func matchFields(terms []string, fields []string, weights []float64) (*SearchResult, bool) {
+	result := &SearchResult{}
+	matched := make([]bool, len(terms))
+	bestMatches := 0
+
+	for i, field := range fields {
+		words := searchWords(field)
+		matches := 0
+		snippet := field
+
+		// Words are marked from the end of the field, so the offsets of earlier words stay the same.
+		for j := len(words) - 1; j >= 0; j-- {
+			word := words[j]
+			isMatch := false
+			for k, term := range terms {
+				if strings.HasPrefix(strings.ToLower(word.text), term) {
+					matched[k] = true
+					isMatch = true
+				}
+			}
+
+			if isMatch {
+				matches++
+				result.Rank += weights[i]
+				snippet = snippet[:word.start] + matchStart + word.text + matchEnd + snippet[word.end:]
+			}
+		}
+
+		if matches > bestMatches {
+			bestMatches = matches
+			result.Snippet = highlight(snippet)
+		}
+	}
+
+	return result, !slices.Contains(matched, false)
+}
+
+// A word in a text, and where it starts and ends.
+type searchWord struct {
+	text       string
+	start, end int
+}
+
+// Splits the text into words, like the search index does.
+func searchWords(text string) []searchWord {
+	words := []searchWord{}
+	start := -1
+	for i, r := range text + " " {
+		isWordCharacter := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWordCharacter && start < 0 {
+			start = i
+		} else if !isWordCharacter && start >= 0 {
+			words = append(words, searchWord{text: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	return words
+}
+
+// Splits a search query into lowercase terms. Everything but letters and numbers is left out,
+// so the terms can safely be used in the query syntax of the search index.
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, word := range searchWords(query) {
+		terms = append(terms, strings.ToLower(word.text))
+	}
+	return terms
+}
+
+// Sorts the results by their rank, best first.
+func sortByRank(results []SearchResult) {
+	slices.SortStableFunc(results, func(a SearchResult, b SearchResult) int {
+		if a.Rank > b.Rank {
+			return -1
+		}
+		if a.Rank < b.Rank {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Returns the amount of search results to return for the requested limit.
+func searchLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSearchLimit
+	}
+	return min(limit, MaxLimit)
+}
+
+// Escapes the snippet, and wraps the matches in <mark> tags.
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, matchStart, "<mark>")
+	return strings.ReplaceAll(snippet, matchEnd, "</mark>")
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+	"wishlist-backend/migrations"
+)
+
+// Adds a wishlist that is owned by the identity, with an item for every name.
+func addWishlist(t *testing.T, repos *Repositories, owner string, name string, items ...string) (*Wishlist, []Item) {
+	t.Helper()
+
+	wishlist, err := repos.Wishlists.Add(Wishlist{Name: name, Ownership: owner}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := []Item{}
+	for _, name := range items {
+		item, err := repos.Items.Add(Item{WishlistId: wishlist.Id, Name: name, Url: "https://shop.example.com/" + name, Quantity: 1}, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added = append(added, *item)
+	}
+	return wishlist, added
+}
+
+// Checks that the search returns the models with the given IDs, in any order.
+func expectResults(t *testing.T, repos *Repositories, ownership string, query string, ids ...string) {
+	t.Helper()
+
+	results, err := repos.Search.Search(ownership, query, DefaultSearchLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := []string{}
+	for _, result := range results {
+		found = append(found, result.Id)
+	}
+
+	slices.Sort(found)
+	slices.Sort(ids)
+	if !slices.Equal(found, ids) {
+		t.Errorf("searching %q found %v, want %v", query, found, ids)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, items := addWishlist(t, repos, "owner", "Birthday presents", "Lego bricks", "Birthday cake")
+		addWishlist(t, repos, "stranger", "Birthday of someone else")
+
+		expectResults(t, repos, "owner", "birth", wishlist.Id, items[1].Id)
+		expectResults(t, repos, "owner", "brick", items[0].Id)
+		expectResults(t, repos, "owner", "shop.example", items[0].Id, items[1].Id)
+		expectResults(t, repos, "owner", "irth")
+
+		if err := repos.Items.DeleteById(items[1].Id, "owner"); err != nil {
+			t.Fatal(err)
+		}
+		expectResults(t, repos, "owner", "cake")
+	})
+}
+
+func TestSearchWithoutFullText(t *testing.T) {
+	db := openSQLite(t)
+	if err := migrations.Latest(db, migrations.NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := NewSQLRepositories(db)
+	wishlist, items := addWishlist(t, repos, "owner", "Birthday presents", "Lego bricks", "Birthday cake")
+
+	expectResults(t, repos, "owner", "birth", wishlist.Id, items[1].Id)
+	expectResults(t, repos, "owner", "lego brick", items[0].Id)
+	expectResults(t, repos, "owner", "irth")
+
+	// Transactions search the same kind of index.
+	err := repos.Transaction(func(repos *Repositories) error {
+		expectResults(t, repos, "owner", "cake", items[1].Id)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
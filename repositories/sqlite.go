@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// The name of the SQLite driver that registers the functions the migrations need on every connection.
+const SQLiteDriver = "sqlite3_wishlist"
+
+func init() {
+	sql.Register(SQLiteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// Hashes the passwords of wishlists like the repositories do.
+			return conn.RegisterFunc("sha256_hex", HashPassword, true)
+		},
+	})
+}
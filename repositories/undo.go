@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// Returned when there are no changes left to undo or redo.
+var ErrNothingToUndo = errors.New("there are no changes to undo or redo")
+
+// Reverts the last changes the actor made to the wishlist and its items, starting with the most recent one.
+// Either all of the changes are reverted, or none of them are.
+//
+// Returns the changes that were made by reverting them. If a model was changed by someone else since,
+// ErrVersionConflict is returned.
+func (repo *AuditRepository) Undo(wishlistId string, actor string, steps int) ([]AuditEntry, error) {
+	return repo.replay(wishlistId, actor, steps, true)
+}
+
+// Applies the last changes the actor undid to the wishlist and its items again, in the order they were
+// originally made in. Either all of the changes are applied, or none of them are.
+func (repo *AuditRepository) Redo(wishlistId string, actor string, steps int) ([]AuditEntry, error) {
+	return repo.replay(wishlistId, actor, steps, false)
+}
+
+func (repo *AuditRepository) replay(wishlistId string, actor string, steps int, undo bool) ([]AuditEntry, error) {
+	changes := []AuditEntry{}
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		targets := []AuditEntry{}
+		query := tx.From("AuditLog").Where(
+			goqu.C("WishlistId").Eq(wishlistId),
+			goqu.C("Actor").Eq(actor),
+			goqu.C("Action").In(undoableActions),
+		).Limit(uint(steps))
+
+		// Changes are undone from new to old, and redone from old to new.
+		if undo {
+			query = query.Where(goqu.C("State").Eq(AuditApplied)).Order(goqu.C("CreatedAt").Desc(), goqu.C("Id").Desc())
+		} else {
+			query = query.Where(goqu.C("State").Eq(AuditUndone)).Order(goqu.C("CreatedAt").Asc(), goqu.C("Id").Asc())
+		}
+
+		if err := query.ScanStructs(&targets); err != nil {
+			return err
+		}
+
+		if len(targets) <= 0 {
+			return ErrNothingToUndo
+		}
+
+		for _, target := range targets {
+			change, err := replayEntry(tx, target, actor, undo)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, *change)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Undoes or redoes a single change, and records the result in the audit log.
+func replayEntry(tx *goqu.TxDatabase, target AuditEntry, actor string, undo bool) (*AuditEntry, error) {
+	action, state, from, to := replayOf(target, undo)
+
+	var change *AuditEntry
+	var err error
+	switch target.Entity {
+	case "Wishlist":
+		change, err = transition[Wishlist](tx, target.Entity, target.EntityId, from, to, actor, action)
+	case "Item":
+		change, err = transition[Item](tx, target.Entity, target.EntityId, from, to, actor, action)
+	default:
+		err = errors.New("changes to " + target.Entity + " can not be undone")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Update("AuditLog").
+		Set(goqu.Record{"State": state}).
+		Where(goqu.C("Id").Eq(target.Id), goqu.C("State").Eq(target.State)).
+		Executor().Exec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count, err := result.RowsAffected(); err != nil || count != 1 {
+		return nil, errors.Join(ErrVersionConflict, err)
+	}
+
+	change.Target = target.Id
+	if _, err := tx.Insert("AuditLog").Rows(change).Executor().Exec(); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// Moves the model with the given ID from one state to another, and returns the audit entry of that change.
+func transition[T interface{}](tx *goqu.TxDatabase, table string, id string, from JSON, to JSON, actor string, action string) (*AuditEntry, error) {
+	var current T
+	found, err := tx.From(table).Where(goqu.C("Id").Eq(id)).ScanStruct(&current)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The model was purged in the meantime.
+	if !found {
+		return nil, ErrVersionConflict
+	}
+
+	next, err := nextState(current, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := exp.NewRecordFromStruct(*next, false, true)
+	if err != nil {
+		return nil, err
+	}
+	record["Version"] = getVersion(*next)
+	record["DeletedAt"] = getDeletedAt(*next)
+
+	result, err := tx.Update(table).Set(record).
+		Where(goqu.C("Id").Eq(id), goqu.C("Version").Eq(getVersion(current))).
+		Executor().Exec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count, err := result.RowsAffected(); err != nil || count != 1 {
+		return nil, errors.Join(ErrVersionConflict, err)
+	}
+
+	return newAuditEntry(actor, action, table, live(&current), live(next))
+}
+
+// Returns the action of undoing or redoing the change, the state the change ends up in, and
+// the states the model moves from and to.
+func replayOf(target AuditEntry, undo bool) (string, string, JSON, JSON) {
+	if undo {
+		return AuditUndo, AuditUndone, target.After, target.Before
+	}
+	return AuditRedo, AuditApplied, target.Before, target.After
+}
+
+// Returns the model after moving it from one state to another. An empty state means the model
+// is in the trash.
+//
+// Returns ErrVersionConflict if the model is no longer in the state it is moved from.
+func nextState[T interface{}](current T, from JSON, to JSON) (*T, error) {
+	if (len(from) <= 0) != (getDeletedAt(current) != nil) {
+		return nil, ErrVersionConflict
+	}
+
+	next := current
+	if len(from) > 0 {
+		if same, err := hasState(current, from); err != nil || !same {
+			return nil, errors.Join(ErrVersionConflict, err)
+		}
+	}
+
+	switch {
+	case len(to) <= 0:
+		deletedAt := time.Now().UTC()
+		setDeletedAt(&next, &deletedAt)
+	case len(from) <= 0:
+		setDeletedAt(&next, nil)
+	default:
+		// Fields that are not part of the state, like the owner of a wishlist, keep their current value.
+		if err := json.Unmarshal([]byte(to), &next); err != nil {
+			return nil, err
+		}
+		keepSkippedFields(current, &next)
+		setVersion(&next, getVersion(current)+1)
+	}
+
+	return &next, nil
+}
+
+// Returns whether the model is in the given state, regardless of its version and the other fields
+// that are not part of the state, like the position of an item.
+func hasState[T interface{}](model T, state JSON) (bool, error) {
+	expected := model
+	if err := json.Unmarshal([]byte(state), &expected); err != nil {
+		return false, err
+	}
+	keepSkippedFields(model, &expected)
+	setVersion(&expected, getVersion(model))
+
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+
+	modelJSON, err := json.Marshal(model)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(expectedJSON, modelJSON), nil
+}
+
+// Returns the model, or nil if the model is in the trash.
+func live[T interface{}](model *T) *T {
+	if getDeletedAt(*model) != nil {
+		return nil
+	}
+	return model
+}
+
+// A repository of models whose changes can be undone.
+type memoryEntity interface {
+	// Moves the model with the given ID from one state to another, and returns the audit entry of that change.
+	transition(id string, from JSON, to JSON, actor string, action string) (*AuditEntry, error)
+}
+
+// Reverts the last changes the actor made to the wishlist and its items, starting with the most recent one.
+// Either all of the changes are reverted, or none of them are.
+func (repo *MemoryAuditRepository) Undo(wishlistId string, actor string, steps int) ([]AuditEntry, error) {
+	return repo.replay(wishlistId, actor, steps, true)
+}
+
+// Applies the last changes the actor undid to the wishlist and its items again, in the order they were
+// originally made in. Either all of the changes are applied, or none of them are.
+func (repo *MemoryAuditRepository) Redo(wishlistId string, actor string, steps int) ([]AuditEntry, error) {
+	return repo.replay(wishlistId, actor, steps, false)
+}
+
+func (repo *MemoryAuditRepository) replay(wishlistId string, actor string, steps int, undo bool) ([]AuditEntry, error) {
+	if repo.transaction == nil {
+		return repo.replayEntries(wishlistId, actor, steps, undo)
+	}
+
+	var changes []AuditEntry
+	err := repo.transaction(func(repos *Repositories) error {
+		var err error
+		changes, err = repo.replayEntries(wishlistId, actor, steps, undo)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Undoes or redoes the changes one by one. The changes that were made before an error stay made,
+// unless they are rolled back by the transaction replay runs them in.
+func (repo *MemoryAuditRepository) replayEntries(wishlistId string, actor string, steps int, undo bool) ([]AuditEntry, error) {
+	state := AuditApplied
+	if !undo {
+		state = AuditUndone
+	}
+
+	repo.mutex.RLock()
+	targets := slices.DeleteFunc(slices.Clone(repo.entries), func(entry AuditEntry) bool {
+		return entry.WishlistId != wishlistId || entry.Actor != actor || entry.State != state ||
+			!slices.Contains(undoableActions, entry.Action)
+	})
+	repo.mutex.RUnlock()
+
+	// Entries are stored from old to new. Changes are undone from new to old, and redone from old to new.
+	if undo {
+		slices.Reverse(targets)
+	}
+	targets = targets[:min(steps, len(targets))]
+
+	if len(targets) <= 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	changes := []AuditEntry{}
+	for _, target := range targets {
+		action, state, from, to := replayOf(target, undo)
+
+		entity, ok := repo.entities[target.Entity]
+		if !ok {
+			return changes, errors.New("changes to " + target.Entity + " can not be undone")
+		}
+
+		// The repository of the model is locked while the change is made, so the audit log
+		// can not be locked at the same time.
+		change, err := entity.transition(target.EntityId, from, to, actor, action)
+		if err != nil {
+			return changes, err
+		}
+		change.Target = target.Id
+
+		repo.mutex.Lock()
+		for i := range repo.entries {
+			if repo.entries[i].Id == target.Id {
+				repo.entries[i].State = state
+			}
+		}
+		repo.entries = append(repo.entries, *change)
+		repo.mutex.Unlock()
+
+		changes = append(changes, *change)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+// Returns the name of the wishlist.
+func wishlistName(t *testing.T, repos *Repositories, id string) string {
+	t.Helper()
+
+	wishlist, err := repos.Wishlists.GetById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wishlist.Name
+}
+
+func TestUndoRedo(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, items := addWishlist(t, repos, "owner", "Birthday", "Bricks")
+		item := items[0]
+
+		item.Name = "Red bricks"
+		updated, err := repos.Items.Update(item, &item.Id, "owner")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wishlist.Name = "Christmas"
+		if _, err := repos.Wishlists.Update(*wishlist, &wishlist.Id, "owner"); err != nil {
+			t.Fatal(err)
+		}
+
+		updated.Name = "Blue bricks"
+		if _, err := repos.Items.Update(*updated, &item.Id, "collaborator"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The rename of the wishlist can be undone, but the item was changed by someone else since.
+		if _, err := repos.Audit.Undo(wishlist.Id, "owner", 2); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("undoing a change that was changed since returned %v, want %v", err, ErrVersionConflict)
+		}
+
+		if name := wishlistName(t, repos, wishlist.Id); name != "Christmas" {
+			t.Errorf("name = %q after a failed undo, want %q", name, "Christmas")
+		}
+
+		if _, err := repos.Audit.Undo(wishlist.Id, "owner", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if name := wishlistName(t, repos, wishlist.Id); name != "Birthday" {
+			t.Errorf("name = %q after undoing the rename, want %q", name, "Birthday")
+		}
+
+		if _, err := repos.Audit.Redo(wishlist.Id, "owner", 5); err != nil {
+			t.Fatal(err)
+		}
+
+		if name := wishlistName(t, repos, wishlist.Id); name != "Christmas" {
+			t.Errorf("name = %q after redoing the rename, want %q", name, "Christmas")
+		}
+
+		if _, err := repos.Audit.Redo(wishlist.Id, "owner", 1); !errors.Is(err, ErrNothingToUndo) {
+			t.Errorf("redoing without undone changes returned %v, want %v", err, ErrNothingToUndo)
+		}
+	})
+}
+
+func TestUndoDelete(t *testing.T) {
+	testRepositories(t, func(t *testing.T, repos *Repositories) {
+		wishlist, items := addWishlist(t, repos, "owner", "Birthday", "Bricks")
+
+		if err := repos.Items.DeleteById(items[0].Id, "owner"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Audit.Undo(wishlist.Id, "owner", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repos.Items.GetById(items[0].Id); err != nil {
+			t.Errorf("the item is not back after undoing its deletion: %v", err)
+		}
+	})
+}
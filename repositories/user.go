@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Returned when a model can not be added, because it would not be unique.
+var ErrAlreadyExists = errors.New("the model already exists")
+
+// An account that can log in with its email and password.
+type User struct {
+	Id        string    `json:"id" db:"Id"`
+	CreatedAt time.Time `json:"createdAt" db:"CreatedAt"`
+	// Always stored in lowercase, so every address can only be registered once.
+	Email        string `json:"email" db:"Email"`
+	Name         string `json:"name" db:"Name"`
+	PasswordHash string `json:"-" db:"PasswordHash"`
+}
+
+// A session of a user, or of an anonymous caller. Only a hash of the refresh token of the session
+// is stored, which is used as its ID.
+type Session struct {
+	Id string `json:"-" db:"Id"`
+	// The identity the session was created for. This is the ID of the user for users, and a
+	// random ID for anonymous callers.
+	Subject   string    `json:"-" db:"Subject"`
+	CreatedAt time.Time `json:"createdAt" db:"CreatedAt"`
+	ExpiresAt time.Time `json:"expiresAt" db:"ExpiresAt"`
+}
+
+// A login at an external identity provider that was started, but not finished yet. Only a hash of
+// the state that is passed through the identity provider is stored, which is used as its ID.
+type LoginAttempt struct {
+	Id string `db:"Id"`
+	// The PKCE code verifier, which proves to the identity provider that the code is redeemed by
+	// whoever started the login.
+	Verifier string `db:"Verifier"`
+	// Binds the ID token the identity provider issues to this attempt.
+	Nonce     string    `db:"Nonce"`
+	CreatedAt time.Time `db:"CreatedAt"`
+	ExpiresAt time.Time `db:"ExpiresAt"`
+}
+
+// An account of a user at an external identity provider, which they can log in with.
+type ExternalIdentity struct {
+	Issuer    string    `db:"Issuer"`
+	Subject   string    `db:"Subject"`
+	UserId    string    `db:"UserId"`
+	CreatedAt time.Time `db:"CreatedAt"`
+}
+
+type UserRepository struct {
+	db Database
+}
+
+func NewUserRepository(db Database) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Adds the user, generating its ID. Returns ErrAlreadyExists if the email address is already registered.
+func (repo *UserRepository) AddUser(user User) (*User, error) {
+	user.Id = NewId()
+	user.CreatedAt = time.Now().UTC()
+
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		count, err := tx.From("User").Where(goqu.C("Email").Eq(user.Email)).Count()
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrAlreadyExists
+		}
+
+		_, err = tx.Insert("User").Rows(user).Executor().Exec()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (repo *UserRepository) GetUserById(id string) (*User, error) {
+	return repo.getUser(goqu.C("Id").Eq(id))
+}
+
+func (repo *UserRepository) GetUserByEmail(email string) (*User, error) {
+	return repo.getUser(goqu.C("Email").Eq(email))
+}
+
+func (repo *UserRepository) getUser(condition goqu.Expression) (*User, error) {
+	var user User
+	found, err := repo.db.From("User").Where(condition).ScanStruct(&user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
+	return &user, nil
+}
+
+// Adds a user that logs in with an external identity provider, together with that identity.
+// Returns ErrAlreadyExists if the email address is already registered.
+func (repo *UserRepository) AddExternalUser(user User, identity ExternalIdentity) (*User, error) {
+	var added *User
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		users := NewUserRepository(tx)
+
+		var err error
+		added, err = users.AddUser(user)
+		if err != nil {
+			return err
+		}
+
+		identity.UserId = added.Id
+		return users.LinkExternalIdentity(identity)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// Lets the user log in with the identity of an external identity provider.
+func (repo *UserRepository) LinkExternalIdentity(identity ExternalIdentity) error {
+	identity.CreatedAt = time.Now().UTC()
+	_, err := repo.db.Insert("ExternalIdentity").Rows(identity).Executor().Exec()
+	return err
+}
+
+// Returns the user that logs in with the subject of the identity provider.
+func (repo *UserRepository) GetUserByExternalIdentity(issuer string, subject string) (*User, error) {
+	linked := repo.db.From("ExternalIdentity").Select("UserId").Where(
+		goqu.C("Issuer").Eq(issuer),
+		goqu.C("Subject").Eq(subject),
+	)
+	return repo.getUser(goqu.C("Id").In(linked))
+}
+
+func (repo *UserRepository) AddLoginAttempt(attempt LoginAttempt) error {
+	_, err := repo.db.Insert("LoginAttempt").Rows(attempt).Executor().Exec()
+	return err
+}
+
+// Removes the login attempt with the given ID, if it has not expired at the given moment, and
+// returns it. An attempt can only be taken once, so it can only be finished once.
+func (repo *UserRepository) TakeLoginAttempt(id string, at time.Time) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		found, err := tx.From("LoginAttempt").Where(
+			goqu.C("Id").Eq(id),
+			goqu.C("ExpiresAt").Gt(at.UTC()),
+		).ScanStruct(&attempt)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		result, err := tx.Delete("LoginAttempt").Where(goqu.C("Id").Eq(id)).Executor().Exec()
+		return checkAffected(result, err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (repo *UserRepository) AddSession(session Session) error {
+	_, err := repo.db.Insert("Session").Rows(session).Executor().Exec()
+	return err
+}
+
+// Removes the session with the given ID, if it has not expired at the given moment, and returns it.
+// A session can only be taken once, so its refresh token can only be used once.
+func (repo *UserRepository) TakeSession(id string, at time.Time) (*Session, error) {
+	var session Session
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		found, err := tx.From("Session").Where(
+			goqu.C("Id").Eq(id),
+			goqu.C("ExpiresAt").Gt(at.UTC()),
+		).ScanStruct(&session)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		result, err := tx.Delete("Session").Where(goqu.C("Id").Eq(id)).Executor().Exec()
+		if err != nil {
+			return err
+		}
+
+		if count, err := result.RowsAffected(); err != nil || count != 1 {
+			return errors.Join(os.ErrNotExist, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (repo *UserRepository) DeleteSession(id string) error {
+	_, err := repo.db.Delete("Session").Where(goqu.C("Id").Eq(id)).Executor().Exec()
+	return err
+}
+
+// Returns the identity that replaced the session key that was used before accounts existed, and
+// forgets the key, so it can only be exchanged once. Keys are looked up by their hash.
+func (repo *UserRepository) ExchangeLegacyKey(key string) (string, error) {
+	var identity string
+	hash := HashPassword(key)
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		found, err := tx.From("LegacyKey").Select("Identity").Where(goqu.C("KeyHash").Eq(hash)).ScanVal(&identity)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		_, err = tx.Delete("LegacyKey").Where(goqu.C("KeyHash").Eq(hash)).Executor().Exec()
+		return err
+	})
+
+	return identity, err
+}
+
+// Permanently removes the sessions and login attempts that expired before the given moment.
+func (repo *UserRepository) Purge(before time.Time) (int64, error) {
+	var count int64
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		for _, table := range []string{"Session", "LoginAttempt"} {
+			result, err := tx.Delete(table).
+				Where(goqu.C("ExpiresAt").Lt(before.UTC())).
+				Executor().Exec()
+
+			if err != nil {
+				return err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			count += affected
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// Keeps users, their sessions and their API keys in memory.
+type MemoryUserRepository struct {
+	mutex      *sync.RWMutex
+	users      map[string]User
+	sessions   map[string]Session
+	attempts   map[string]LoginAttempt
+	identities []ExternalIdentity
+	apiKeys    []ApiKey
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		mutex:      &sync.RWMutex{},
+		users:      map[string]User{},
+		sessions:   map[string]Session{},
+		attempts:   map[string]LoginAttempt{},
+		identities: []ExternalIdentity{},
+		apiKeys:    []ApiKey{},
+	}
+}
+
+// Adds the user, generating its ID. Returns ErrAlreadyExists if the email address is already registered.
+func (repo *MemoryUserRepository) AddUser(user User) (*User, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, stored := range repo.users {
+		if stored.Email == user.Email {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	user.Id = NewId()
+	user.CreatedAt = time.Now().UTC()
+	repo.users[user.Id] = user
+	return &user, nil
+}
+
+func (repo *MemoryUserRepository) GetUserById(id string) (*User, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	user, ok := repo.users[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &user, nil
+}
+
+func (repo *MemoryUserRepository) GetUserByEmail(email string) (*User, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, user := range repo.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// Adds a user that logs in with an external identity provider, together with that identity.
+// Returns ErrAlreadyExists if the email address is already registered.
+func (repo *MemoryUserRepository) AddExternalUser(user User, identity ExternalIdentity) (*User, error) {
+	added, err := repo.AddUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.UserId = added.Id
+	return added, repo.LinkExternalIdentity(identity)
+}
+
+// Lets the user log in with the identity of an external identity provider.
+func (repo *MemoryUserRepository) LinkExternalIdentity(identity ExternalIdentity) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, stored := range repo.identities {
+		if stored.Issuer == identity.Issuer && stored.Subject == identity.Subject {
+			return ErrAlreadyExists
+		}
+	}
+
+	identity.CreatedAt = time.Now().UTC()
+	repo.identities = append(repo.identities, identity)
+	return nil
+}
+
+// Returns the user that logs in with the subject of the identity provider.
+func (repo *MemoryUserRepository) GetUserByExternalIdentity(issuer string, subject string) (*User, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, identity := range repo.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			if user, ok := repo.users[identity.UserId]; ok {
+				return &user, nil
+			}
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (repo *MemoryUserRepository) AddLoginAttempt(attempt LoginAttempt) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.attempts[attempt.Id] = attempt
+	return nil
+}
+
+// Removes the login attempt with the given ID, if it has not expired at the given moment, and returns it.
+func (repo *MemoryUserRepository) TakeLoginAttempt(id string, at time.Time) (*LoginAttempt, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	attempt, ok := repo.attempts[id]
+	if !ok || !attempt.ExpiresAt.After(at) {
+		return nil, os.ErrNotExist
+	}
+
+	delete(repo.attempts, id)
+	return &attempt, nil
+}
+
+func (repo *MemoryUserRepository) AddSession(session Session) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.sessions[session.Id] = session
+	return nil
+}
+
+// Removes the session with the given ID, if it has not expired at the given moment, and returns it.
+func (repo *MemoryUserRepository) TakeSession(id string, at time.Time) (*Session, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	session, ok := repo.sessions[id]
+	if !ok || !session.ExpiresAt.After(at) {
+		return nil, os.ErrNotExist
+	}
+
+	delete(repo.sessions, id)
+	return &session, nil
+}
+
+// Memory repositories are never used with session keys from before accounts existed.
+func (repo *MemoryUserRepository) ExchangeLegacyKey(key string) (string, error) {
+	return "", os.ErrNotExist
+}
+
+func (repo *MemoryUserRepository) DeleteSession(id string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	delete(repo.sessions, id)
+	return nil
+}
+
+// Permanently removes the sessions and login attempts that expired before the given moment.
+func (repo *MemoryUserRepository) Purge(before time.Time) (int64, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var count int64
+	for id, session := range repo.sessions {
+		if session.ExpiresAt.Before(before) {
+			delete(repo.sessions, id)
+			count++
+		}
+	}
+
+	for id, attempt := range repo.attempts {
+		if attempt.ExpiresAt.Before(before) {
+			delete(repo.attempts, id)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+	"wishlist-backend/migrations"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+func TestExchangeLegacyKey(t *testing.T) {
+	// Session keys were stored as the owners of wishlists before accounts existed.
+	db := openSQLite(t)
+	if err := migrations.Migrate(db, 8, migrations.NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Insert("Wishlist").Rows(goqu.Record{
+		"Id":        "wishlist",
+		"Name":      "Birthday",
+		"Ownership": "legacy key",
+		"CreatedAt": time.Now().UTC(),
+	}).Executor().Exec()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrations.Latest(db, migrations.NoFTS5); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := []string{}
+	if err := db.From("LegacyKey").Select("KeyHash").ScanVals(&hashes); err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 1 || hashes[0] != HashPassword("legacy key") {
+		t.Errorf("legacy keys = %v, want only the hash of the key", hashes)
+	}
+
+	repos := NewSQLRepositories(db)
+	identity, err := repos.Users.ExchangeLegacyKey("legacy key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wishlist, err := repos.Wishlists.GetById("wishlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wishlist.Ownership != identity || identity == "legacy key" {
+		t.Errorf("key was exchanged for %q, want the identity that owns the wishlist, %q", identity, wishlist.Ownership)
+	}
+
+	if _, err := repos.Users.ExchangeLegacyKey("legacy key"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("exchanging a key twice returned %v, want %v", err, os.ErrNotExist)
+	}
+}
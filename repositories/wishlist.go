@@ -1,34 +1,72 @@
 package repository
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"os"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 )
 
 type Wishlist struct {
 	Model[string]
-	Name      string `json:"name" db:"Name"`
-	Password  string `json:"-" db:"Password" goqu:"skipinsert"`
-	Ownership string `json:"-" db:"Ownership"`
+	Name string `json:"name" db:"Name"`
+	// The password that gives edit permissions. It is never stored, so it is only set right after
+	// it was generated.
+	Password     string `json:"-" db:"-"`
+	PasswordHash string `json:"-" db:"PasswordHash" goqu:"skipupdate"`
+	Ownership    string `json:"-" db:"Ownership" goqu:"skipupdate"`
+}
+
+// What was moved from one identity to another when it was claimed.
+type Claimed struct {
+	// The amount of wishlists the identity owned, including those in the trash.
+	Wishlists int64 `json:"wishlists"`
+	// The amount of wishlists the identity had permissions for.
+	Collaborations int64 `json:"collaborations"`
+}
+
+// Returned when the password of a wishlist is incorrect.
+var ErrInvalidPassword = errors.New("the password of the wishlist is incorrect")
+
+// Returns the hash of a wishlist password, invitation token or session key from before accounts
+// existed, which is stored instead of the secret itself. The secrets are random IDs, so they are
+// too long to guess and do not need a slow hash.
+func HashPassword(password string) string {
+	hash := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns whether the password matches the stored hash, in constant time.
+func verifyPassword(hash string, password string) bool {
+	return len(hash) > 0 && subtle.ConstantTimeCompare([]byte(hash), []byte(HashPassword(password))) == 1
+}
+
+func (wishlist Wishlist) AuditScope() string {
+	return wishlist.Id
 }
 
 type WishlistBody struct {
 	Model[string]
 	Name      string `json:"name"`
 	Ownership string `json:"ownership"`
+	// The URLs of the items the wishlist is created with.
+	Items []string `json:"items"`
 }
 
 type UnlockedWishlist struct {
 	Model[string]
 	Name      string `json:"name" db:"Name"`
-	Password  string `json:"password" db:"Password" goqu:"skipinsert"`
-	Ownership string `json:"ownership" db:"Ownership"`
+	Password  string `json:"password"`
+	Ownership string `json:"ownership"`
 }
 
 type WishlistRepository struct {
-	*AbstractSQLiteRepository[Wishlist, string]
+	*AbstractSQLRepository[Wishlist, string]
 }
 
 type WishlistViewer struct {
@@ -36,6 +74,8 @@ type WishlistViewer struct {
 	Wishlist    Wishlist `json:"wishlist" db:"-"`
 	Permissions string   `json:"permission" db:"Permissions"`
 	Ownership   string   `json:"-" db:"Ownership"`
+	// The moment the viewer first opened the wishlist.
+	CreatedAt time.Time `json:"createdAt" db:"CreatedAt"`
 }
 
 type WishlistPermissioned struct {
@@ -43,29 +83,101 @@ type WishlistPermissioned struct {
 	Permissions string `json:"permission" db:"Permissions"`
 }
 
-func NewWishlistRepository(db *goqu.Database) *WishlistRepository {
+func NewWishlistRepository(db Database) *WishlistRepository {
 	repo := &WishlistRepository{
-		&AbstractSQLiteRepository[Wishlist, string]{
+		&AbstractSQLRepository[Wishlist, string]{
 			db:     db,
 			dbName: "Wishlist",
 			empty:  Wishlist{},
+			newId:  NewId,
 		},
 	}
 	return repo
 }
 
-func (repo *WishlistRepository) GetItems(id string) ([]Item, error) {
-	var items []Item
-	err := repo.db.From("Item").Where(goqu.C("WishlistId").Eq(id)).ScanStructs(&items)
+// Stores a wishlist and generates its password. Only a hash of the password is stored, so the
+// returned wishlist is the only place the password can be read from.
+func (repo *WishlistRepository) Add(wishlist Wishlist, actor string) (*Wishlist, error) {
+	password := NewId()
+	wishlist.PasswordHash = HashPassword(password)
+
+	result, err := repo.AbstractSQLRepository.Add(wishlist, actor)
 	if err != nil {
 		return nil, err
 	}
-	return items, nil
+
+	result.Password = password
+	return result, nil
+}
+
+// Replaces the password of the wishlist with a new one and returns the wishlist with that password,
+// so links with the old password no longer give edit permissions. When demote is set, viewers
+// that already have edit permissions lose them as well.
+func (repo *WishlistRepository) RotatePassword(id string, demote bool) (*Wishlist, error) {
+	password := NewId()
+	wishlist := Wishlist{}
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		found, err := tx.Update("Wishlist").
+			Set(goqu.Record{"PasswordHash": HashPassword(password)}).
+			Where(goqu.C("Id").Eq(id), goqu.C("DeletedAt").IsNull()).
+			Returning(goqu.Star()).
+			Executor().ScanStruct(&wishlist)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return os.ErrNotExist
+		}
+
+		if !demote {
+			return nil
+		}
+
+		_, err = tx.Update("WishlistViewer").
+			Set(goqu.Record{"Permissions": "VIEW"}).
+			Where(goqu.C("WishlistId").Eq(id), goqu.C("Permissions").Eq("EDIT")).
+			Executor().Exec()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	wishlist.Password = password
+	return &wishlist, nil
+}
+
+func (repo *WishlistRepository) GetItems(id string, query Query) (*Page[Item], error) {
+	return paginate[Item](repo.db.From("Item").Where(
+		goqu.C("WishlistId").Eq(id),
+		goqu.C("DeletedAt").IsNull(),
+	), inWishlistOrder(query))
+}
+
+// Returns the items of the wishlists the owner owns or was given permissions to. Items and
+// wishlists in the trash are left out.
+func (repo *WishlistRepository) GetAccessibleItems(ownership string, query Query) (*Page[Item], error) {
+	viewed := repo.db.From("WishlistViewer").Select("WishlistId").Where(goqu.C("Ownership").Eq(ownership))
+	accessible := repo.db.From("Wishlist").Select("Id").Where(
+		goqu.C("DeletedAt").IsNull(),
+		goqu.Or(goqu.C("Ownership").Eq(ownership), goqu.C("Id").In(viewed)),
+	)
+
+	return paginate[Item](repo.db.From("Item").Where(
+		goqu.C("WishlistId").In(accessible),
+		goqu.C("DeletedAt").IsNull(),
+	), query)
 }
 
 func (repo *WishlistRepository) GetOwnedWishlists(ownership string) ([]Wishlist, error) {
 	var wishlists []Wishlist
-	err := repo.db.From("Wishlist").Where(goqu.C("Ownership").Eq(ownership)).ScanStructs(&wishlists)
+	err := repo.db.From("Wishlist").Where(
+		goqu.C("Ownership").Eq(ownership),
+		goqu.C("DeletedAt").IsNull(),
+	).ScanStructs(&wishlists)
 
 	if err != nil {
 		return nil, err
@@ -81,7 +193,10 @@ func (repo *WishlistRepository) GetSavedWishlists(ownership string) ([]WishlistP
 		goqu.On(goqu.Ex{
 			"WishlistViewer.WishlistId": goqu.I("Wishlist.Id"),
 		}),
-	).Where(goqu.I("WishlistViewer.Ownership").Eq(ownership)).ScanStructs(&wishlists)
+	).Where(
+		goqu.I("WishlistViewer.Ownership").Eq(ownership),
+		goqu.I("Wishlist.DeletedAt").IsNull(),
+	).ScanStructs(&wishlists)
 
 	if err != nil {
 		return nil, err
@@ -90,80 +205,292 @@ func (repo *WishlistRepository) GetSavedWishlists(ownership string) ([]WishlistP
 }
 
 func (repo *WishlistRepository) GetPermission(wishlistId string, ownership string) (string, error) {
-	result, err := repo.db.From("WishlistViewer").Select(goqu.C("Permissions")).Where(goqu.And(
+	var permission string
+	found, err := repo.db.From("WishlistViewer").Select(goqu.C("Permissions")).Where(goqu.And(
 		goqu.C("WishlistId").Eq(wishlistId),
 		goqu.C("Ownership").Eq(ownership),
-	)).Executor().Query()
+	)).ScanVal(&permission)
 
 	if err != nil {
 		return "", err
 	}
 
-	if !result.Next() {
+	if !found {
 		return "", os.ErrNotExist
 	}
 
-	var permission string
-	result.Scan(&permission)
 	return permission, nil
 }
 
 func (repo *WishlistRepository) RegisterPermission(wishlistId string, ownership string, password string) error {
-	var model goqu.Record
 	// If password is present, verify password and change permission from viewer to editor.
+	permissions := "VIEW"
 	if len(password) > 0 {
-		res, err := repo.db.From("Wishlist").Where(goqu.And(
-			goqu.C("Id").Eq(wishlistId),
-			goqu.C("Password").Eq(password),
-		)).Executor().Query()
-
+		wishlist, err := repo.GetById(wishlistId)
 		if err != nil {
 			return err
 		}
 
-		// When no results, return error.
-		if !res.Next() {
-			return os.ErrNotExist
+		if !verifyPassword(wishlist.PasswordHash, password) {
+			return ErrInvalidPassword
 		}
+		permissions = "EDIT"
+	}
+
+	return withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		return grantPermission(tx, wishlistId, ownership, permissions)
+	})
+}
 
-		// Prepare data for insertion/update.
-		model = goqu.Record{
+// Registers the owner as a viewer of the wishlist with the given permissions. Viewers that already
+// have edit permissions keep them, so they do not lose them by opening a link for viewers.
+func grantPermission(tx *goqu.TxDatabase, wishlistId string, ownership string, permissions string) error {
+	var current string
+	found, err := tx.From("WishlistViewer").Select("Permissions").Where(
+		goqu.C("WishlistId").Eq(wishlistId),
+		goqu.C("Ownership").Eq(ownership),
+	).ScanVal(&current)
+
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		_, err = tx.Insert("WishlistViewer").Rows(goqu.Record{
 			"WishlistId":  wishlistId,
-			"Permissions": "EDIT",
 			"Ownership":   ownership,
+			"Permissions": permissions,
+			"CreatedAt":   time.Now().UTC(),
+		}).Executor().Exec()
+		return err
+	}
+
+	if hasPermission(current, permissions) {
+		return nil
+	}
+
+	_, err = tx.Update("WishlistViewer").
+		Set(goqu.Record{"Permissions": permissions}).
+		Where(goqu.C("WishlistId").Eq(wishlistId), goqu.C("Ownership").Eq(ownership)).
+		Executor().Exec()
+	return err
+}
+
+// Returns whether a viewer with the current permissions has the wanted permissions as well.
+func hasPermission(current string, wanted string) bool {
+	return current == "EDIT" || current == wanted
+}
+
+// Returns the viewers of the wishlist, in the order they first opened it.
+func (repo *WishlistRepository) GetViewers(wishlistId string) ([]WishlistViewer, error) {
+	viewers := []WishlistViewer{}
+	err := repo.db.From("WishlistViewer").
+		Where(goqu.C("WishlistId").Eq(wishlistId)).
+		Order(goqu.C("CreatedAt").Asc()).
+		ScanStructs(&viewers)
+
+	if err != nil {
+		return nil, err
+	}
+	return viewers, nil
+}
+
+// Changes the permissions of a viewer of the wishlist. Returns os.ErrNotExist if the owner
+// is not a viewer of the wishlist.
+func (repo *WishlistRepository) SetPermission(wishlistId string, ownership string, permissions string) error {
+	result, err := repo.db.Update("WishlistViewer").
+		Set(goqu.Record{"Permissions": permissions}).
+		Where(goqu.C("WishlistId").Eq(wishlistId), goqu.C("Ownership").Eq(ownership)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Revokes all permissions of a viewer of the wishlist. Returns os.ErrNotExist if the owner
+// is not a viewer of the wishlist.
+func (repo *WishlistRepository) RemoveViewer(wishlistId string, ownership string) error {
+	result, err := repo.db.Delete("WishlistViewer").
+		Where(goqu.C("WishlistId").Eq(wishlistId), goqu.C("Ownership").Eq(ownership)).
+		Executor().Exec()
+
+	return checkAffected(result, err)
+}
+
+// Moves the wishlists and permissions of one identity to another, for example when an anonymous
+// caller registers an account. When both identities are viewers of the same wishlist, the highest
+// of their permissions is kept. The changes, reservations and pledges they made move to the new identity as well.
+func (repo *WishlistRepository) ClaimOwnership(from string, to string) (*Claimed, error) {
+	claimed := &Claimed{}
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		viewers := []WishlistViewer{}
+		if err := tx.From("WishlistViewer").Where(goqu.C("Ownership").Eq(from)).ScanStructs(&viewers); err != nil {
+			return err
 		}
-	} else {
-		// Leave out permission because the database will default to viewer.
-		// This way the user will stay an editor if an update happens instead
-		// of an insert on an existing record with edit permissions.
-		model = goqu.Record{
-			"WishlistId": wishlistId,
-			"Ownership":  ownership,
+
+		for _, viewer := range viewers {
+			count, err := tx.From("WishlistViewer").Where(
+				goqu.C("WishlistId").Eq(viewer.WishlistId),
+				goqu.C("Ownership").Eq(to),
+			).Count()
+
+			if err != nil {
+				return err
+			}
+
+			target := goqu.Ex{"WishlistId": viewer.WishlistId, "Ownership": from}
+			if count > 0 {
+				if err := grantPermission(tx, viewer.WishlistId, to, viewer.Permissions); err != nil {
+					return err
+				}
+				_, err = tx.Delete("WishlistViewer").Where(target).Executor().Exec()
+			} else {
+				_, err = tx.Update("WishlistViewer").Set(goqu.Record{"Ownership": to}).Where(target).Executor().Exec()
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+		claimed.Collaborations = int64(len(viewers))
+
+		result, err := tx.Update("Wishlist").Set(goqu.Record{"Ownership": to}).Where(goqu.C("Ownership").Eq(from)).Executor().Exec()
+		if err != nil {
+			return err
+		}
+
+		if claimed.Wishlists, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		// Owners do not need permissions for their own wishlists.
+		owned := tx.From("Wishlist").Select("Id").Where(goqu.C("Ownership").Eq(to))
+		_, err = tx.Delete("WishlistViewer").
+			Where(goqu.C("Ownership").Eq(to), goqu.C("WishlistId").In(owned)).
+			Executor().Exec()
+
+		if err != nil {
+			return err
+		}
+
+		if err := claimReservations(tx, from, to); err != nil {
+			return err
+		}
+
+		if err := claimPledges(tx, from, to); err != nil {
+			return err
 		}
-	}
 
-	// Check if the user already has permissions for the given wishlist.
-	_, err := repo.GetPermission(wishlistId, ownership)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_, err = tx.Update("AuditLog").Set(goqu.Record{"Actor": to}).Where(goqu.C("Actor").Eq(from)).Executor().Exec()
 		return err
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	// If the user does not have permissions, insert row, otherwise update row.
-	if errors.Is(err, os.ErrNotExist) {
-		_, err = repo.db.From("WishlistViewer").Insert().Rows(model).Executor().Exec()
-	} else if len(password) > 0 {
-		_, err = repo.db.From("WishlistViewer").Update().Where(goqu.And(
-			goqu.C("WishlistId").Eq(wishlistId),
-			goqu.C("Ownership").Eq(ownership),
-		)).Set(model).Executor().Exec()
+	return claimed, nil
+}
+
+// Returns the error of a statement, or os.ErrNotExist if the statement did not affect any rows.
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
 	}
 
+	count, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+
+	if count <= 0 {
+		return os.ErrNotExist
+	}
 	return nil
 }
 
+// Returns the wishlists of the owner that are in the trash.
+func (repo *WishlistRepository) GetDeletedWishlists(ownership string) ([]Wishlist, error) {
+	wishlists := []Wishlist{}
+	err := repo.db.From("Wishlist").Where(
+		goqu.C("Ownership").Eq(ownership),
+		goqu.C("DeletedAt").IsNotNull(),
+	).ScanStructs(&wishlists)
+
+	if err != nil {
+		return nil, err
+	}
+	return wishlists, nil
+}
+
+// Returns the items in the trash that belong to wishlists of the owner. Items of wishlists
+// that are in the trash themselves are left out, they are restored together with their wishlist.
+func (repo *WishlistRepository) GetDeletedItems(ownership string) ([]Item, error) {
+	items := []Item{}
+	err := repo.db.From("Item").Select("Item.*").InnerJoin(
+		goqu.T("Wishlist"),
+		goqu.On(goqu.Ex{
+			"Item.WishlistId": goqu.I("Wishlist.Id"),
+		}),
+	).Where(
+		goqu.I("Wishlist.Ownership").Eq(ownership),
+		goqu.I("Wishlist.DeletedAt").IsNull(),
+		goqu.I("Item.DeletedAt").IsNotNull(),
+	).ScanStructs(&items)
+
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Permanently removes the wishlists that were moved to the trash before the given moment,
+// together with their items, viewers, invitations and history.
+func (repo *WishlistRepository) Purge(before time.Time) (int64, error) {
+	var count int64
+	err := withTx(repo.db, func(tx *goqu.TxDatabase) error {
+		purged := tx.From("Wishlist").Select("Id").Where(goqu.C("DeletedAt").Lt(before.UTC()))
+
+		if _, err := tx.Delete("WishlistViewer").Where(goqu.C("WishlistId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("Invitation").Where(goqu.C("WishlistId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("FailedPasswordAttempt").Where(goqu.C("WishlistId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		purgedItems := tx.From("Item").Select("Id").Where(goqu.C("WishlistId").In(purged))
+		if _, err := tx.Delete("Reservation").Where(goqu.C("ItemId").In(purgedItems)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("Pledge").Where(goqu.C("ItemId").In(purgedItems)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("Item").Where(goqu.C("WishlistId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete("AuditLog").Where(goqu.C("WishlistId").In(purged)).Executor().Exec(); err != nil {
+			return err
+		}
+
+		result, err := tx.Delete("Wishlist").Where(goqu.C("DeletedAt").Lt(before.UTC())).Executor().Exec()
+		if err != nil {
+			return err
+		}
+
+		count, err = result.RowsAffected()
+		return err
+	})
+
+	return count, err
+}
+
 func (repo *WishlistRepository) RemoveId(wishlist *Wishlist) {
 	wishlist.Id = ""
 }
@@ -0,0 +1,118 @@
+package authentication
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidScope   = errors.New("unknown scope, use wishlists:read, items:write or collaborators:manage")
+	ErrInvalidKeyName = errors.New("API keys need a name of at most 100 characters")
+)
+
+// Every API key starts with this prefix, which tells them apart from access tokens.
+const ApiKeyPrefix = "wlk_"
+
+// The maximum length of the name of an API key.
+const maxKeyNameLength = 100
+
+// The scopes that limit what an API key can be used for.
+const (
+	// Allows reading wishlists, their items and searching them.
+	ScopeReadWishlists = "wishlists:read"
+	// Allows adding, changing, removing and restoring items.
+	ScopeWriteItems = "items:write"
+	// Allows managing the collaborators and invitations of wishlists.
+	ScopeManageCollaborators = "collaborators:manage"
+)
+
+var scopes = []string{ScopeReadWishlists, ScopeWriteItems, ScopeManageCollaborators}
+
+// Creates an API key for the user with the space-separated scopes. The returned key is the only
+// place the key itself can be read from.
+func (service *Service) CreateApiKey(userId string, name string, scope string) (*repository.ApiKey, error) {
+	name = strings.TrimSpace(name)
+	if len(name) <= 0 || len([]rune(name)) > maxKeyNameLength {
+		return nil, ErrInvalidKeyName
+	}
+
+	granted := []string{}
+	for _, requested := range strings.Fields(scope) {
+		if !slices.Contains(scopes, requested) {
+			return nil, ErrInvalidScope
+		}
+
+		if !slices.Contains(granted, requested) {
+			granted = append(granted, requested)
+		}
+	}
+
+	if len(granted) <= 0 {
+		return nil, ErrInvalidScope
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	token = ApiKeyPrefix + token
+
+	key, err := service.users.AddApiKey(repository.ApiKey{
+		UserId:  userId,
+		Name:    name,
+		KeyHash: hashToken(token),
+		Scopes:  strings.Join(granted, " "),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	key.Key = token
+	return key, nil
+}
+
+// Returns the API keys of the user, without the keys themselves.
+func (service *Service) GetApiKeys(userId string) ([]repository.ApiKey, error) {
+	return service.users.GetApiKeys(userId)
+}
+
+// Revokes the API key of the user. Returns os.ErrNotExist if the user has no such key.
+func (service *Service) RevokeApiKey(userId string, id string) error {
+	return service.users.DeleteApiKey(userId, id)
+}
+
+// Returns the claims of a caller that authenticates with an API key. Returns os.ErrNotExist for
+// unknown and revoked keys.
+func (service *Service) verifyApiKey(token string) (*Claims, error) {
+	key, err := service.users.UseApiKey(hashToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		Subject:  key.UserId,
+		ApiKeyId: key.Id,
+		Scopes:   strings.Fields(key.Scopes),
+	}, nil
+}
+
+// Returns whether the caller authenticated with an API key instead of a session.
+func UsesApiKey(c *gin.Context) bool {
+	claims := getClaims(c)
+	return claims != nil && len(claims.ApiKeyId) > 0
+}
+
+// Returns whether the caller may do what the scope allows. Callers with a session may do
+// everything, callers with an API key only what its scopes allow.
+func HasScope(c *gin.Context, scope string) bool {
+	if !UsesApiKey(c) {
+		return true
+	}
+	return slices.Contains(getClaims(c).Scopes, scope)
+}
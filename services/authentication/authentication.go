@@ -1 +1,326 @@
 package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+	repository "wishlist-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrWeakPassword       = errors.New("passwords need at least 8 characters")
+	ErrLongPassword       = errors.New("passwords can not be longer than 72 bytes")
+)
+
+// The minimum length of a password.
+const minPasswordLength = 8
+
+// Compared against when a login is attempted for an email address that is not registered,
+// so it takes as long as a login with a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("wishlist"), bcrypt.DefaultCost)
+
+// Registers users, logs them in and resolves their sessions.
+//
+// Callers authenticate with short-lived access tokens that are signed by the server. Every access
+// token belongs to a session, whose refresh token can be used once to get a new pair of tokens.
+// Scripts can authenticate with API keys instead, which last until they are revoked.
+type Service struct {
+	users  repository.UserStore
+	config Config
+	oidc   *oidcProvider
+}
+
+type Config struct {
+	// The key access tokens are signed with.
+	Secret []byte
+	// How long an access token can be used.
+	TokenLifetime time.Duration
+	// How long a session lasts, and so how long its refresh token can be used.
+	SessionLifetime time.Duration
+	// The identity provider users can log in with, next to their email address and password.
+	OIDC OIDCConfig
+}
+
+func New(users repository.UserStore, config Config) *Service {
+	return &Service{users: users, config: config, oidc: newOIDCProvider(config.OIDC)}
+}
+
+// The tokens of a new session. The refresh token is only known at the moment it is issued.
+type Tokens struct {
+	AccessToken string `json:"accessToken"`
+	// The moment the access token expires, after which the refresh token has to be used.
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
+	// The moment the refresh token expires, after which the user has to log in again.
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// The tokens of a session of a user.
+type LoggedIn struct {
+	Tokens
+	User repository.User `json:"user"`
+}
+
+// Creates an account and logs it in.
+func (service *Service) Register(email string, name string, password string) (*LoggedIn, error) {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != strings.TrimSpace(email) {
+		return nil, ErrInvalidEmail
+	}
+
+	if len([]rune(password)) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, ErrLongPassword
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.users.AddUser(repository.User{
+		Email:        strings.ToLower(address.Address),
+		Name:         strings.TrimSpace(name),
+		PasswordHash: string(hash),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return service.logIn(user)
+}
+
+// Logs the user with the email address in, if the password is correct.
+func (service *Service) Login(email string, password string) (*LoggedIn, error) {
+	user, err := service.users.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, os.ErrNotExist) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return service.logIn(user)
+}
+
+// Creates a session for a new anonymous caller, who can use the wishlists without an account.
+func (service *Service) StartAnonymous() (*Tokens, error) {
+	return service.startSession(repository.NewId())
+}
+
+// Ends the session with the given ID. Access tokens of the session stay valid until they expire,
+// but can no longer be refreshed.
+func (service *Service) Logout(sessionId string) error {
+	return service.users.DeleteSession(sessionId)
+}
+
+// Replaces the session of the refresh token with a new session, and returns its tokens. Returns
+// os.ErrNotExist if the session does not exist, has expired, or was already refreshed.
+func (service *Service) Refresh(refreshToken string) (*Tokens, error) {
+	session, err := service.users.TakeSession(hashToken(refreshToken), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return service.startSession(session.Subject)
+}
+
+// Exchanges a session key from before accounts existed for the tokens of a new session. The
+// wishlists of the key were moved to an opaque identity, which the session is created for.
+// Every key can only be exchanged once. Returns os.ErrNotExist for unknown keys.
+func (service *Service) ExchangeLegacyKey(key string) (*Tokens, error) {
+	identity, err := service.users.ExchangeLegacyKey(strings.ToLower(strings.TrimSpace(key)))
+	if err != nil {
+		return nil, err
+	}
+
+	return service.startSession(identity)
+}
+
+// Returns the claims of a valid access token.
+func (service *Service) Verify(accessToken string) (*Claims, error) {
+	return verifyToken(accessToken, service.config.Secret, time.Now())
+}
+
+// Creates a session for the user, and returns its tokens.
+func (service *Service) logIn(user *repository.User) (*LoggedIn, error) {
+	tokens, err := service.startSession(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoggedIn{Tokens: *tokens, User: *user}, nil
+}
+
+// Creates a session for the identity, and returns its tokens.
+func (service *Service) startSession(subject string) (*Tokens, error) {
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := repository.Session{
+		Id:        hashToken(refreshToken),
+		Subject:   subject,
+		CreatedAt: now,
+		ExpiresAt: now.Add(service.config.SessionLifetime),
+	}
+
+	if err := service.users.AddSession(session); err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(service.config.TokenLifetime)
+	accessToken, err := signToken(Claims{
+		Subject:   subject,
+		SessionId: session.Id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, service.config.Secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// Generates a random refresh token.
+func newToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Returns the hash of the refresh token that is stored as the ID of its session.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+const (
+	claimsKey = "claims"
+	userKey   = "user"
+)
+
+// Resolves the caller of every request from the access token or API key in its Authorization header.
+//
+// Requests without a token are anonymous, and can only use the endpoints that do not need an
+// identity. Requests with an invalid or expired token are rejected, so the client can refresh it.
+func (service *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := strings.TrimSpace(c.GetHeader("Authorization"))
+		if len(header) <= 0 {
+			c.Next()
+			return
+		}
+
+		// The scheme is case-insensitive, the token itself is not.
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "bearer") {
+			token = header
+		}
+
+		token = strings.TrimSpace(token)
+		var claims *Claims
+		var err error
+		if strings.HasPrefix(token, ApiKeyPrefix) {
+			claims, err = service.verifyApiKey(token)
+		} else {
+			claims, err = service.Verify(token)
+		}
+
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			c.String(401, "Your session has expired, refresh it or log in again.")
+			c.Abort()
+			return
+		case errors.Is(err, os.ErrNotExist):
+			c.String(401, "This API key is invalid or was revoked.")
+			c.Abort()
+			return
+		case err != nil:
+			c.Error(err)
+			c.String(400, "Something went wrong")
+			c.Abort()
+			return
+		}
+		c.Set(claimsKey, claims)
+
+		user, err := service.users.GetUserById(claims.Subject)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.Error(err)
+			c.String(400, "Something went wrong")
+			c.Abort()
+			return
+		}
+
+		if user != nil {
+			c.Set(userKey, user)
+		}
+
+		c.Next()
+	}
+}
+
+// Returns the identity of the caller, which is used as the owner of their wishlists. Returns an
+// empty string if the caller did not identify themselves.
+func Identity(c *gin.Context) string {
+	if claims := getClaims(c); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// Returns the user that is logged in, or nil if the caller is anonymous.
+func CurrentUser(c *gin.Context) *repository.User {
+	user, ok := c.Get(userKey)
+	if !ok {
+		return nil
+	}
+	return user.(*repository.User)
+}
+
+// Returns the ID of the session of the caller, or an empty string if the caller did not identify themselves.
+func SessionId(c *gin.Context) string {
+	if claims := getClaims(c); claims != nil {
+		return claims.SessionId
+	}
+	return ""
+}
+
+func getClaims(c *gin.Context) *Claims {
+	claims, ok := c.Get(claimsKey)
+	if !ok {
+		return nil
+	}
+	return claims.(*Claims)
+}
@@ -0,0 +1,249 @@
+// Package mockidp is an OpenID Connect provider that logs every user in without asking for
+// credentials, so logins with an identity provider can be tried locally.
+//
+// The authorization endpoint logs the user in with the email address in the login_hint parameter,
+// or user@example.com if there is none. The subject of a user is derived from their email address,
+// so logging in with the same address always returns the same identity.
+package mockidp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The ID of the key ID tokens are signed with.
+const keyId = "mock"
+
+// How long an authorization code can be redeemed.
+const codeLifetime = time.Minute
+
+// A login that was authorized, waiting for its code to be redeemed.
+type grant struct {
+	clientId    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	email       string
+	expiresAt   time.Time
+}
+
+// A mock identity provider. It serves the discovery document, the authorization, token and JWKS
+// endpoints relative to its issuer.
+type Provider struct {
+	issuer string
+	key    *rsa.PrivateKey
+	mutex  *sync.Mutex
+	grants map[string]grant
+	mux    *http.ServeMux
+}
+
+// Creates a provider with the given issuer URL, which is the URL the provider is served at.
+// A new signing key is generated every time.
+func New(issuer string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &Provider{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		key:    key,
+		mutex:  &sync.Mutex{},
+		grants: map[string]grant{},
+		mux:    http.NewServeMux(),
+	}
+
+	provider.mux.HandleFunc("GET /.well-known/openid-configuration", provider.discovery)
+	provider.mux.HandleFunc("GET /authorize", provider.authorize)
+	provider.mux.HandleFunc("POST /token", provider.token)
+	provider.mux.HandleFunc("GET /jwks", provider.jwks)
+
+	return provider, nil
+}
+
+func (provider *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	provider.mux.ServeHTTP(w, r)
+}
+
+func (provider *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                provider.issuer,
+		"authorization_endpoint":                provider.issuer + "/authorize",
+		"token_endpoint":                        provider.issuer + "/token",
+		"jwks_uri":                              provider.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// Logs the user in right away, and sends them back to the redirect URI with a code.
+func (provider *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" || len(query.Get("client_id")) <= 0 {
+		http.Error(w, "only the authorization code flow is supported", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge_method") != "S256" || len(query.Get("code_challenge")) <= 0 {
+		http.Error(w, "a S256 code challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(query.Get("login_hint")))
+	if len(email) <= 0 {
+		email = "user@example.com"
+	}
+
+	code := randomString()
+	now := time.Now()
+	provider.mutex.Lock()
+	// Codes that were never redeemed are forgotten once they expire.
+	for code, grant := range provider.grants {
+		if now.After(grant.expiresAt) {
+			delete(provider.grants, code)
+		}
+	}
+
+	provider.grants[code] = grant{
+		clientId:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		email:       email,
+		expiresAt:   now.Add(codeLifetime),
+	}
+	provider.mutex.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// Redeems a code for an ID token, once.
+func (provider *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientId, _, ok := r.BasicAuth()
+	if ok {
+		clientId, _ = url.QueryUnescape(clientId)
+	} else {
+		clientId = r.PostForm.Get("client_id")
+	}
+
+	provider.mutex.Lock()
+	code := r.PostForm.Get("code")
+	grant, ok := provider.grants[code]
+	delete(provider.grants, code)
+	provider.mutex.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || time.Now().After(grant.expiresAt) ||
+		grant.clientId != clientId ||
+		grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		grant.challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	subject := sha256.Sum256([]byte(grant.email))
+	now := time.Now()
+	idToken, err := provider.sign(map[string]any{
+		"iss":            provider.issuer,
+		"sub":            hex.EncodeToString(subject[:16]),
+		"aud":            grant.clientId,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": true,
+		"name":           strings.Split(grant.email, "@")[0],
+	})
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (provider *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	public := provider.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyId,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// Returns the claims as a JWT that is signed with RS256.
+func (provider *Provider) sign(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyId})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, provider.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func randomString() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
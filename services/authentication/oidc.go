@@ -0,0 +1,470 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	repository "wishlist-backend/repositories"
+)
+
+var (
+	ErrOIDCDisabled        = errors.New("login with an identity provider is not configured")
+	ErrInvalidLoginAttempt = errors.New("the login attempt is unknown or has expired")
+	ErrInvalidIdToken      = errors.New("the identity provider returned an invalid ID token")
+	ErrMissingEmail        = errors.New("the identity provider did not share an email address")
+	// Returned when the email address of an external identity is registered to an account, but the
+	// identity provider did not verify the address belongs to the user.
+	ErrEmailTaken = errors.New("the email address is already registered")
+)
+
+const (
+	// How long a user can take to log in at the identity provider.
+	loginAttemptLifetime = 10 * time.Minute
+	// How far the clock of the identity provider may differ from ours.
+	clockSkew = time.Minute
+)
+
+// The identity provider users can log in with, using the OpenID Connect authorization code flow.
+type OIDCConfig struct {
+	// The URL of the identity provider, its endpoints are discovered from it. Login with an
+	// identity provider is disabled when it is empty.
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// The page the identity provider sends users back to, which passes the code and state it
+	// receives on to the API.
+	RedirectURL string
+}
+
+// The part of the discovery document of an identity provider that is used to log in.
+type discoveryDocument struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// The claims of an ID token that are used to find or create the account of the user.
+type idTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	Party     string   `json:"azp"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+	Email     string   `json:"email"`
+	// Some identity providers send this as a string.
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// The aud claim, which is either a single client or a list of them.
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(aud))
+}
+
+// Logs users in with an identity provider. The discovery document and signing keys of the
+// provider are fetched when they are first needed.
+type oidcProvider struct {
+	config OIDCConfig
+	client *http.Client
+	mutex  *sync.Mutex
+	// Nil until the discovery document was fetched.
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+}
+
+func newOIDCProvider(config OIDCConfig) *oidcProvider {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &oidcProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		mutex:  &sync.Mutex{},
+		keys:   map[string]*rsa.PublicKey{},
+	}
+}
+
+// Returns whether users can log in with an identity provider.
+func (service *Service) OIDCEnabled() bool {
+	return len(service.config.OIDC.Issuer) > 0
+}
+
+// A login that was started at the identity provider.
+type OIDCLogin struct {
+	// The URL the user has to be sent to.
+	AuthorizationURL string
+	// The hash of the state of the login. It is kept by the browser that started the login, and
+	// has to be presented with the state to finish it, so nobody can finish a login of their own
+	// in the browser of someone else.
+	StateHash string
+	ExpiresAt time.Time
+}
+
+// Starts a login at the identity provider.
+func (service *Service) StartOIDCLogin() (*OIDCLogin, error) {
+	if !service.OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	discovery, err := service.oidc.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	attempt := repository.LoginAttempt{
+		Id:        hashToken(state),
+		Verifier:  verifier,
+		Nonce:     nonce,
+		CreatedAt: now,
+		ExpiresAt: now.Add(loginAttemptLifetime),
+	}
+
+	if err := service.users.AddLoginAttempt(attempt); err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {service.config.OIDC.ClientId},
+		"redirect_uri":          {service.config.OIDC.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return &OIDCLogin{
+		AuthorizationURL: discovery.AuthorizationEndpoint + separator + query.Encode(),
+		StateHash:        attempt.Id,
+		ExpiresAt:        attempt.ExpiresAt,
+	}, nil
+}
+
+// Finishes the login the state belongs to, with the code the identity provider sent the user back
+// with. The hash of the state has to be the one that was returned when the login was started.
+// Logs the user in to the account of their external identity. The account is created when it does
+// not exist yet, or linked when the identity provider verified the email address of an existing account.
+func (service *Service) FinishOIDCLogin(state string, code string, stateHash string) (*LoggedIn, error) {
+	if !service.OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	id := hashToken(state)
+	if subtle.ConstantTimeCompare([]byte(id), []byte(stateHash)) != 1 {
+		return nil, ErrInvalidLoginAttempt
+	}
+
+	attempt, err := service.users.TakeLoginAttempt(id, time.Now())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrInvalidLoginAttempt
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := service.oidc.exchange(code, attempt.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Nonce != attempt.Nonce {
+		return nil, ErrInvalidIdToken
+	}
+
+	user, err := service.externalUser(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.logIn(user)
+}
+
+// Returns the account of the external identity, creating or linking it if needed.
+func (service *Service) externalUser(claims *idTokenClaims) (*repository.User, error) {
+	user, err := service.users.GetUserByExternalIdentity(claims.Issuer, claims.Subject)
+	if !errors.Is(err, os.ErrNotExist) {
+		return user, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if len(email) <= 0 {
+		return nil, ErrMissingEmail
+	}
+
+	identity := repository.ExternalIdentity{Issuer: claims.Issuer, Subject: claims.Subject}
+
+	user, err = service.users.GetUserByEmail(email)
+	if errors.Is(err, os.ErrNotExist) {
+		// Accounts of external identities have no password, so they can only log in with the identity provider.
+		return service.users.AddExternalUser(repository.User{
+			Email: email,
+			Name:  strings.TrimSpace(claims.Name),
+		}, identity)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !isTrue(claims.EmailVerified) {
+		return nil, ErrEmailTaken
+	}
+
+	identity.UserId = user.Id
+	if err := service.users.LinkExternalIdentity(identity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Returns whether a claim is true, whether it was sent as a boolean or as a string.
+func isTrue(claim any) bool {
+	return claim == true || claim == "true"
+}
+
+// Returns the discovery document of the identity provider.
+func (provider *oidcProvider) discover() (*discoveryDocument, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	discovery := &discoveryDocument{}
+	if err := provider.getJSON(provider.config.Issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != provider.config.Issuer {
+		return nil, fmt.Errorf("the identity provider identifies itself as %s instead of %s", discovery.Issuer, provider.config.Issuer)
+	}
+
+	provider.discovery = discovery
+	return discovery, nil
+}
+
+// Redeems the authorization code at the token endpoint, and returns the claims of the ID token
+// after validating it.
+func (provider *oidcProvider) exchange(code string, verifier string) (*idTokenClaims, error) {
+	discovery, err := provider.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"client_id":     {provider.config.ClientId},
+		"code_verifier": {verifier},
+	}
+
+	// Clients only authenticate with a single method, which is HTTP basic authentication unless
+	// the identity provider does not support it.
+	useBasic := len(discovery.TokenAuthMethods) <= 0 || slices.Contains(discovery.TokenAuthMethods, "client_secret_basic")
+	if len(provider.config.ClientSecret) > 0 && !useBasic {
+		form.Set("client_secret", provider.config.ClientSecret)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	if len(provider.config.ClientSecret) > 0 && useBasic {
+		request.SetBasicAuth(url.QueryEscape(provider.config.ClientId), url.QueryEscape(provider.config.ClientSecret))
+	}
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the identity provider rejected the code with status %d", response.StatusCode)
+	}
+
+	body := struct {
+		IdToken string `json:"id_token"`
+	}{}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return provider.verifyIdToken(body.IdToken, discovery, time.Now())
+}
+
+// Verifies the signature and claims of the ID token, and returns its claims. Only RS256 signed
+// tokens are accepted, which every OpenID provider has to support.
+func (provider *oidcProvider) verifyIdToken(token string, discovery *discoveryDocument, at time.Time) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIdToken
+	}
+
+	header := struct {
+		Algorithm string `json:"alg"`
+		KeyId     string `json:"kid"`
+	}{}
+
+	if err := decodeSegment(parts[0], &header); err != nil || header.Algorithm != "RS256" {
+		return nil, ErrInvalidIdToken
+	}
+
+	key, err := provider.key(header.KeyId, discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIdToken
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, ErrInvalidIdToken
+	}
+
+	claims := &idTokenClaims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidIdToken
+	}
+
+	clientId := provider.config.ClientId
+	switch {
+	case claims.Issuer != discovery.Issuer,
+		len(claims.Subject) <= 0,
+		!slices.Contains(claims.Audience, clientId),
+		len(claims.Audience) > 1 && claims.Party != clientId,
+		at.Add(-clockSkew).Unix() >= claims.ExpiresAt,
+		at.Add(clockSkew).Unix() < claims.IssuedAt:
+		return nil, ErrInvalidIdToken
+	}
+
+	return claims, nil
+}
+
+// Returns the signing key with the given ID. The keys are fetched again when the key is unknown,
+// because identity providers rotate their keys.
+func (provider *oidcProvider) key(id string, discovery *discoveryDocument) (*rsa.PublicKey, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, ok := provider.keys[id]; ok {
+		return key, nil
+	}
+
+	jwks := struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyId   string `json:"kid"`
+			Use     string `json:"use"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}{}
+
+	if err := provider.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "RSA" || (len(jwk.Use) > 0 && jwk.Use != "sig") {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+
+		keys[jwk.KeyId] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	provider.keys = keys
+
+	key, ok := keys[id]
+	if !ok {
+		return nil, ErrInvalidIdToken
+	}
+	return key, nil
+}
+
+func (provider *oidcProvider) getJSON(url string, target any) error {
+	response, err := provider.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("the identity provider responded to %s with status %d", url, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// Decodes a base64url encoded JSON segment of a JWT.
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
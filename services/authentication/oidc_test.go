@@ -0,0 +1,105 @@
+package authentication
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	repository "wishlist-backend/repositories"
+	"wishlist-backend/services/authentication/mockidp"
+)
+
+// Creates a service that logs users in with a mock identity provider.
+func newOIDCService(t *testing.T) *Service {
+	t.Helper()
+
+	var provider *mockidp.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := mockidp.New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(repository.NewMemoryUserRepository(), Config{
+		Secret:          []byte("test secret"),
+		TokenLifetime:   time.Minute,
+		SessionLifetime: time.Hour,
+		OIDC: OIDCConfig{
+			Issuer:      server.URL,
+			ClientId:    "wishlist",
+			RedirectURL: "http://localhost:5173/login",
+		},
+	})
+}
+
+// Logs in at the identity provider as the user with the email address, and returns the state and
+// code it sends the user back with.
+func authorize(t *testing.T, login *OIDCLogin, email string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	response, err := client.Get(login.AuthorizationURL + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	redirect, err := response.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirect.Query().Get("state"), redirect.Query().Get("code")
+}
+
+func TestFinishOIDCLogin(t *testing.T) {
+	service := newOIDCService(t)
+
+	login, err := service.StartOIDCLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := authorize(t, login, "Ada@example.com")
+
+	// A login can only be finished by the browser that started it, and trying does not use it up.
+	if _, err := service.FinishOIDCLogin(state, code, ""); !errors.Is(err, ErrInvalidLoginAttempt) {
+		t.Fatalf("finishing without the state hash returned %v, want %v", err, ErrInvalidLoginAttempt)
+	}
+
+	first, err := service.FinishOIDCLogin(state, code, login.StateHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.User.Email != "ada@example.com" || len(first.AccessToken) <= 0 {
+		t.Errorf("logged in as %q with access token %q, want ada@example.com with a token", first.User.Email, first.AccessToken)
+	}
+
+	if _, err := service.FinishOIDCLogin(state, code, login.StateHash); !errors.Is(err, ErrInvalidLoginAttempt) {
+		t.Errorf("finishing a login twice returned %v, want %v", err, ErrInvalidLoginAttempt)
+	}
+
+	// Logging in with the same identity again returns the same account.
+	login, err = service.StartOIDCLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code = authorize(t, login, "ada@example.com")
+
+	second, err := service.FinishOIDCLogin(state, code, login.StateHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.User.Id != first.User.Id {
+		t.Errorf("logged in to account %q, want %q", second.User.Id, first.User.Id)
+	}
+}
@@ -0,0 +1,77 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Returned when an access token is malformed, has an invalid signature, or has expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// The header of every access token. Only HMAC-SHA256 signed tokens are issued and accepted.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// The claims of an access token.
+type Claims struct {
+	// The identity of the caller, which is stored as the owner of their wishlists.
+	Subject string `json:"sub"`
+	// The ID of the session the token was issued for.
+	SessionId string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	// The ID of the API key the caller authenticated with, and its scopes. Never part of an access token.
+	ApiKeyId string   `json:"-"`
+	Scopes   []string `json:"-"`
+}
+
+// Creates an access token that is signed with the secret. The token is a JWT, so clients can read
+// its claims, but only the server can create valid ones.
+func signToken(claims Claims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + signature(unsigned, secret), nil
+}
+
+// Verifies the signature and expiry of the access token, and returns its claims.
+func verifyToken(token string, secret []byte, at time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+
+	expected := signature(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if len(claims.Subject) <= 0 || at.Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func signature(unsigned string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
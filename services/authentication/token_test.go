@@ -0,0 +1,97 @@
+package authentication
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	repository "wishlist-backend/repositories"
+)
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("test secret")
+	now := time.Now()
+	token, err := signToken(Claims{Subject: "ada", SessionId: "session", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := verifyToken(token, secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "ada" || claims.SessionId != "session" {
+		t.Errorf("claims = %+v, want the subject and session the token was signed for", claims)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+
+	invalid := map[string]struct {
+		token  string
+		secret []byte
+		at     time.Time
+	}{
+		"expired":        {token, secret, now.Add(time.Minute)},
+		"other secret":   {token, []byte("other secret"), now},
+		"forged claims":  {forged, secret, now},
+		"unsigned":       {unsigned, secret, now},
+		"not a token":    {"token", secret, now},
+		"missing claims": {parts[0] + ".." + parts[2], secret, now},
+	}
+
+	for name, test := range invalid {
+		if _, err := verifyToken(test.token, test.secret, test.at); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("verifying a token that is %s returned %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	service := New(repository.NewMemoryUserRepository(), Config{
+		Secret:          []byte("test secret"),
+		TokenLifetime:   time.Minute,
+		SessionLifetime: time.Hour,
+	})
+
+	tokens, err := service.StartAnonymous()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := service.Verify(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := service.Refresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshedClaims, err := service.Verify(refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new session belongs to the same caller.
+	if refreshedClaims.Subject != claims.Subject || refreshedClaims.SessionId == claims.SessionId {
+		t.Errorf("refreshed claims = %+v, want a new session of %q", refreshedClaims, claims.Subject)
+	}
+
+	if _, err := service.Refresh(tokens.RefreshToken); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("refreshing a session twice returned %v, want %v", err, os.ErrNotExist)
+	}
+
+	if err := service.Logout(refreshedClaims.SessionId); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Refresh(refreshed.RefreshToken); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("refreshing a session that was logged out returned %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -15,6 +15,10 @@ type ogpData struct {
 	Image       string `json:"imageUrl"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	// The price of the product on the page as a decimal number with two decimals, and its
+	// ISO 4217 currency code. Both are empty if the page has no price.
+	PriceAmount   string `json:"price,omitempty"`
+	PriceCurrency string `json:"currency,omitempty"`
 }
 
 // The relevant attributes of an OGP meta HTML tag.
@@ -56,6 +60,11 @@ func GetOGPData(url string) (*ogpData, error) {
 		metadata.Image, _ = GetFavicon(head)
 	}
 
+	if amount, currency, ok := getPrice(node); ok {
+		metadata.PriceAmount = amount
+		metadata.PriceCurrency = currency
+	}
+
 	return &metadata, nil
 }
 
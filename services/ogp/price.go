@@ -0,0 +1,294 @@
+package ogp
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// The meta tags that hold the price of a product, by the Open Graph and Facebook product conventions.
+var (
+	amountProperties   = []string{"product:price:amount", "og:price:amount"}
+	currencyProperties = []string{"product:price:currency", "og:price:currency"}
+)
+
+// The currencies of the symbols that are common in formatted prices. Symbols that are shared by
+// several currencies, like kr and $, are left out.
+var currencySymbols = map[string]string{
+	"€":  "EUR",
+	"£":  "GBP",
+	"¥":  "JPY",
+	"₹":  "INR",
+	"₩":  "KRW",
+	"₺":  "TRY",
+	"₽":  "RUB",
+	"zł": "PLN",
+}
+
+var (
+	currencyCode = regexp.MustCompile(`\b[A-Z]{3}\b`)
+	// A number, possibly with thousands separators and a decimal separator.
+	priceNumber = regexp.MustCompile(`[0-9][0-9.,' \x{00a0}\x{202f}]*`)
+	// A machine-readable number, which always uses a point as its decimal separator.
+	plainNumber = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// A price as it is written on a page, before it is normalised.
+type rawPrice struct {
+	Amount   string
+	Currency string
+	// Whether the amount is text that is formatted for a locale, instead of a machine-readable
+	// number like the content of a meta tag.
+	Formatted bool
+}
+
+// Returns the price of the product on the page as a decimal number with two decimals, like 1299.00,
+// and its ISO 4217 currency code. Meta tags are preferred over schema.org data in JSON-LD, which is
+// preferred over microdata. Returns false if the page has no price with a known currency.
+func getPrice(node *html.Node) (string, string, bool) {
+	candidates := []rawPrice{getMetaPrice(node)}
+	candidates = append(candidates, getJSONLDPrices(node)...)
+	candidates = append(candidates, getMicrodataPrice(node))
+
+	for _, candidate := range candidates {
+		if amount, currency, ok := normalisePrice(candidate.Amount, candidate.Currency, candidate.Formatted); ok {
+			return amount, currency, true
+		}
+	}
+	return "", "", false
+}
+
+// Returns the price in the product:price:* or og:price:* meta tags.
+func getMetaPrice(node *html.Node) rawPrice {
+	price := rawPrice{}
+	for _, meta := range *getHTMLElements(node, "meta") {
+		key := getAttribute(meta, "property")
+		if len(key) <= 0 {
+			key = getAttribute(meta, "name")
+		}
+
+		key = strings.ToLower(key)
+		for _, property := range amountProperties {
+			if key == property && len(price.Amount) <= 0 {
+				price.Amount = getAttribute(meta, "content")
+			}
+		}
+
+		for _, property := range currencyProperties {
+			if key == property && len(price.Currency) <= 0 {
+				price.Currency = getAttribute(meta, "content")
+			}
+		}
+	}
+	return price
+}
+
+// Returns the prices of the schema.org offers in the JSON-LD scripts of the page.
+func getJSONLDPrices(node *html.Node) []rawPrice {
+	prices := []rawPrice{}
+	for _, script := range *getHTMLElements(node, "script") {
+		if !strings.EqualFold(getAttribute(script, "type"), "application/ld+json") || script.FirstChild == nil {
+			continue
+		}
+
+		var data interface{}
+		if err := json.Unmarshal([]byte(script.FirstChild.Data), &data); err != nil {
+			continue
+		}
+		prices = append(prices, findOffers(data)...)
+	}
+	return prices
+}
+
+// Searches the JSON-LD data for objects with a price and a currency, like an Offer, an
+// AggregateOffer or a PriceSpecification.
+func findOffers(data interface{}) []rawPrice {
+	prices := []rawPrice{}
+	switch data := data.(type) {
+	case []interface{}:
+		for _, value := range data {
+			prices = append(prices, findOffers(value)...)
+		}
+	case map[string]interface{}:
+		currency, _ := data["priceCurrency"].(string)
+		for _, key := range []string{"price", "lowPrice"} {
+			if amount := jsonNumber(data[key]); len(amount) > 0 && len(currency) > 0 {
+				prices = append(prices, rawPrice{Amount: amount, Currency: currency})
+				break
+			}
+		}
+
+		// The offers of a product come before other nested objects, like the offers of related products.
+		for _, key := range []string{"offers", "priceSpecification", "@graph"} {
+			prices = append(prices, findOffers(data[key])...)
+		}
+	}
+	return prices
+}
+
+// Returns a price in JSON-LD, which can be a number or a string.
+func jsonNumber(value interface{}) string {
+	switch value := value.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	case string:
+		return value
+	}
+	return ""
+}
+
+// Returns the price in the itemprop="price" and itemprop="priceCurrency" microdata of the page.
+func getMicrodataPrice(node *html.Node) rawPrice {
+	price := rawPrice{}
+	for _, element := range findElements(node, func(node *html.Node) bool { return len(getAttribute(node, "itemprop")) > 0 }) {
+		value := getAttribute(element, "content")
+		formatted := len(value) <= 0
+		if formatted {
+			value = strings.TrimSpace(getText(element))
+		}
+
+		switch getAttribute(element, "itemprop") {
+		case "price":
+			if len(price.Amount) <= 0 {
+				price.Amount = value
+				price.Formatted = formatted
+			}
+		case "priceCurrency":
+			if len(price.Currency) <= 0 {
+				price.Currency = value
+			}
+		}
+	}
+	return price
+}
+
+// Normalises a price to a decimal number with two decimals and an ISO 4217 currency code. Formatted
+// prices can be written for a locale, like "1.299,00 €" or "1,299.99 USD", other prices need to be
+// machine-readable numbers like 1299.99. The currency is read from the price itself when it is not given.
+func normalisePrice(amount string, currency string, formatted bool) (string, string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) <= 0 {
+		currency = currencyCode.FindString(amount)
+	}
+
+	if len(currency) <= 0 {
+		for symbol, code := range currencySymbols {
+			if strings.Contains(amount, symbol) {
+				currency = code
+				break
+			}
+		}
+	}
+
+	if len(currency) != 3 || !currencyCode.MatchString(currency) {
+		return "", "", false
+	}
+
+	value, ok := 0.0, false
+	if formatted {
+		value, ok = parseNumber(priceNumber.FindString(amount))
+	} else if amount = strings.TrimSpace(amount); plainNumber.MatchString(amount) {
+		value, ok = parseFloat(amount)
+	}
+
+	if !ok {
+		return "", "", false
+	}
+
+	return strconv.FormatFloat(value, 'f', 2, 64), currency, true
+}
+
+// Parses a number that uses either a point or a comma as its decimal separator. A single separator
+// that is followed by exactly three digits is taken to separate thousands, like in 1.299 or 1,299.
+func parseNumber(number string) (float64, bool) {
+	number = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\'' || r == '\u00a0' || r == '\u202f' {
+			return -1
+		}
+		return r
+	}, number)
+	number = strings.TrimRight(number, ".,")
+
+	decimal := ""
+	dot, comma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		decimal = "."
+		if comma > dot {
+			decimal = ","
+		}
+	case dot >= 0 || comma >= 0:
+		separator, index := ".", dot
+		if comma >= 0 {
+			separator, index = ",", comma
+		}
+
+		if strings.Count(number, separator) == 1 && (len(number)-index-1 != 3 || number[:index] == "0") {
+			decimal = separator
+		}
+	}
+
+	var normalised strings.Builder
+	for _, r := range number {
+		switch {
+		case string(r) == decimal:
+			normalised.WriteRune('.')
+		case r >= '0' && r <= '9':
+			normalised.WriteRune(r)
+		}
+	}
+
+	return parseFloat(normalised.String())
+}
+
+// Parses a number with a point as its decimal separator.
+func parseFloat(number string) (float64, bool) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// Returns the value of the attribute of the HTML element, or an empty string if it has none.
+func getAttribute(node *html.Node, key string) string {
+	for _, attribute := range node.Attr {
+		if strings.EqualFold(attribute.Key, key) {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+// Traverses the HTML node tree and returns all HTML elements that match.
+func findElements(node *html.Node, matches func(*html.Node) bool) []*html.Node {
+	if node == nil {
+		return []*html.Node{}
+	}
+
+	nodes := []*html.Node{}
+	if node.Type == html.ElementNode && matches(node) {
+		nodes = append(nodes, node)
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, findElements(child, matches)...)
+	}
+	return nodes
+}
+
+// Returns the text in the HTML node and its descendants.
+func getText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(getText(child))
+	}
+	return text.String()
+}
@@ -0,0 +1,138 @@
+package ogp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   float64
+		ok     bool
+	}{
+		{"1.299,00", 1299, true},
+		{"1,299.99", 1299.99, true},
+		{"0,99", 0.99, true},
+		{"1 299,00", 1299, true},
+		{"1\u00a0299,00", 1299, true},
+		{"1'299.50", 1299.5, true},
+		{"1.299", 1299, true},
+		{"1,299", 1299, true},
+		{"0.299", 0.299, true},
+		{"12,5", 12.5, true},
+		{"1.234.567,89", 1234567.89, true},
+		{"12,", 12, true},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseNumber(test.number)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseNumber(%q) = %v, %v, want %v, %v", test.number, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNormalisePrice(t *testing.T) {
+	tests := []struct {
+		amount       string
+		currency     string
+		formatted    bool
+		wantAmount   string
+		wantCurrency string
+		ok           bool
+	}{
+		{"1.299,00 €", "", true, "1299.00", "EUR", true},
+		{"$1,299.99", "usd", true, "1299.99", "USD", true},
+		{"0,99", "EUR", true, "0.99", "EUR", true},
+		{"1 299,00", "EUR", true, "1299.00", "EUR", true},
+		{"£12.50", "", true, "12.50", "GBP", true},
+		{"1,299.99 CAD", "", true, "1299.99", "CAD", true},
+		// The dollar is used by too many currencies to tell which one is meant.
+		{"$1,299.99", "", true, "", "", false},
+		{"C$ 12.99", "", true, "", "", false},
+		{"1.299,00", "", true, "", "", false},
+		// Machine-readable amounts always use a point as their decimal separator.
+		{"1.299", "EUR", false, "1.30", "EUR", true},
+		{"1299.5", "eur", false, "1299.50", "EUR", true},
+		{" 19 ", "USD", false, "19.00", "USD", true},
+		{"1.299,00", "EUR", false, "", "", false},
+		{"1e3", "EUR", false, "", "", false},
+		{"12.99", "EURO", false, "", "", false},
+	}
+
+	for _, test := range tests {
+		amount, currency, ok := normalisePrice(test.amount, test.currency, test.formatted)
+		if ok != test.ok || amount != test.wantAmount || currency != test.wantCurrency {
+			t.Errorf("normalisePrice(%q, %q, %v) = %q, %q, %v, want %q, %q, %v", test.amount, test.currency, test.formatted,
+				amount, currency, ok, test.wantAmount, test.wantCurrency, test.ok)
+		}
+	}
+}
+
+func TestGetPrice(t *testing.T) {
+	tests := []struct {
+		name         string
+		page         string
+		wantAmount   string
+		wantCurrency string
+		ok           bool
+	}{
+		{
+			name:         "meta tags",
+			page:         `<head><meta property="product:price:amount" content="1299.00"><meta property="product:price:currency" content="EUR"></head>`,
+			wantAmount:   "1299.00",
+			wantCurrency: "EUR",
+			ok:           true,
+		},
+		{
+			name:         "JSON-LD offer with a string price",
+			page:         `<script type="application/ld+json">{"@type":"Product","offers":{"@type":"Offer","price":"1.299","priceCurrency":"KWD"}}</script>`,
+			wantAmount:   "1.30",
+			wantCurrency: "KWD",
+			ok:           true,
+		},
+		{
+			name:         "JSON-LD aggregate offer in a graph",
+			page:         `<script type="application/ld+json">{"@graph":[{"@type":"Product","offers":{"@type":"AggregateOffer","lowPrice":49.5,"priceCurrency":"USD"}}]}</script>`,
+			wantAmount:   "49.50",
+			wantCurrency: "USD",
+			ok:           true,
+		},
+		{
+			name:         "microdata text",
+			page:         `<div itemscope><span itemprop="price">1.299,00 €</span></div>`,
+			wantAmount:   "1299.00",
+			wantCurrency: "EUR",
+			ok:           true,
+		},
+		{
+			name:         "microdata content",
+			page:         `<div itemscope><meta itemprop="priceCurrency" content="EUR"><span itemprop="price" content="1.299">1,30 €</span></div>`,
+			wantAmount:   "1.30",
+			wantCurrency: "EUR",
+			ok:           true,
+		},
+		{
+			name: "no price",
+			page: `<head><meta property="og:title" content="Bricks"></head>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := html.Parse(strings.NewReader(test.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			amount, currency, ok := getPrice(node)
+			if ok != test.ok || amount != test.wantAmount || currency != test.wantCurrency {
+				t.Errorf("getPrice() = %q, %q, %v, want %q, %q, %v", amount, currency, ok, test.wantAmount, test.wantCurrency, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package purge
+
+import (
+	"log"
+	"time"
+)
+
+// A repository with a trash that can be emptied.
+type Purger interface {
+	// Permanently removes the models that were moved to the trash before the given moment.
+	Purge(before time.Time) (int64, error)
+}
+
+// Periodically removes everything that has been in the trash for longer than the retention period.
+// The first purge happens immediately.
+//
+// Returns a function that stops the job.
+func Schedule(interval time.Duration, retention time.Duration, purgers ...Purger) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan bool)
+
+	go func() {
+		Run(retention, purgers...)
+		for {
+			select {
+			case <-ticker.C:
+				Run(retention, purgers...)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Removes everything that has been in the trash for longer than the retention period.
+// Failures are logged, and do not prevent the remaining purgers from running.
+func Run(retention time.Duration, purgers ...Purger) {
+	before := time.Now().Add(-retention)
+
+	for _, purger := range purgers {
+		count, err := purger.Purge(before)
+		if err != nil {
+			log.Printf("purging the trash failed: %v", err)
+			continue
+		}
+
+		if count > 0 {
+			log.Printf("purged %d models from the trash", count)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Returns an error if the search index of the database is an FTS5 table, which can not be used
+// when the driver was built without FTS5. The database was then migrated by a driver that had it.
+func checkSearchIndex(db *goqu.Database) error {
+	var count int
+	_, err := db.From("sqlite_master").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("name").Eq("SearchIndex"), goqu.C("sql").ILike("%USING fts5%")).
+		ScanVal(&count)
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("the search index of this database needs full-text search, build with -tags sqlite_fts5")
+	}
+	return nil
+}